@@ -0,0 +1,139 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package analyzer
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+const (
+	defaultLicense  = "ASL2"
+	defaultLicensor = "Elasticsearch B.V."
+)
+
+// Analyzer reports the files which don't start with the license header and
+// suggests a fix which inserts it.
+var Analyzer = New(defaultLicense, defaultLicensor)
+
+// New returns an Analyzer which checks the header of the license for the
+// licensor. Both can be overridden with the -license and -licensor flags.
+func New(license, licensor string) *analysis.Analyzer {
+	var c = checker{license: license, licensor: licensor}
+
+	var fs flag.FlagSet
+	fs.StringVar(&c.license, "license", license, "sets the license type to check")
+	fs.StringVar(&c.licensor, "licensor", licensor, "sets the name of the licensor")
+
+	return &analysis.Analyzer{
+		Name:  "licenser",
+		Doc:   "checks that Go source files start with the license header\n\nThe fix inserts the header, replacing any other license header found, and separates the header from the package clause when it is attached to it as the package documentation. The files opting out with a go-licenser directive are skipped.",
+		Flags: fs,
+		Run:   c.run,
+	}
+}
+
+type checker struct {
+	license  string
+	licensor string
+}
+
+func (c *checker) run(pass *analysis.Pass) (interface{}, error) {
+	header, err := licensing.Default.Render(c.license, licensing.Params{Licensor: c.licensor})
+	if err != nil {
+		return nil, err
+	}
+
+	var headerBytes []byte
+	for _, line := range header {
+		headerBytes = append(headerBytes, line+"\n"...)
+	}
+
+	var readFile = pass.ReadFile
+	if readFile == nil {
+		readFile = os.ReadFile
+	}
+
+	for _, f := range pass.Files {
+		var tf = pass.Fset.File(f.Pos())
+		if tf == nil {
+			continue
+		}
+
+		src, err := readFile(tf.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		// The files opted out by a directive, like third-party code, keep
+		// their header. Malformed directives are reported without a fix.
+		if d := licensing.ParseDirectives(bytes.NewReader(src)); d.Present() {
+			for _, p := range d.Problems {
+				pass.Reportf(tf.LineStart(1), "invalid go-licenser directive: %s", p)
+			}
+			continue
+		}
+
+		var pos, message, fix = tf.LineStart(1), fmt.Sprintf("file is missing the %s license header", c.license), "Add the %s license header"
+		if licensing.ContainsHeader(bytes.NewReader(src), header) {
+			if doc, _ := licensing.HeaderIsPackageDoc(src); !doc {
+				continue
+			}
+			pos, message, fix = f.Package, fmt.Sprintf("the %s license header is attached to the package clause as its documentation", c.license), "Separate the %s license header from the package clause"
+		}
+
+		var fixed = licensing.RewriteSourceWithHeader(tf.Name(), src, append([]byte(nil), headerBytes...))
+		var start, end, text = diff(src, fixed)
+		pass.Report(analysis.Diagnostic{
+			Pos:     pos,
+			Message: message,
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message: fmt.Sprintf(fix, c.license),
+				TextEdits: []analysis.TextEdit{{
+					Pos:     tf.Pos(start),
+					End:     tf.Pos(end),
+					NewText: text,
+				}},
+			}},
+		})
+	}
+
+	return nil, nil
+}
+
+// diff returns the smallest edit which turns src into dst: the bytes of src
+// between start and end are replaced by text.
+func diff(src, dst []byte) (start, end int, text []byte) {
+	for start < len(src) && start < len(dst) && src[start] == dst[start] {
+		start++
+	}
+
+	var suffix int
+	for suffix < len(src)-start && suffix < len(dst)-start &&
+		src[len(src)-1-suffix] == dst[len(dst)-1-suffix] {
+		suffix++
+	}
+
+	return start, len(src) - suffix, dst[start : len(dst)-suffix]
+}
@@ -0,0 +1,50 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package analyzer
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), Analyzer, "a")
+}
+
+func Test_diff(t *testing.T) {
+	tests := []struct {
+		name      string
+		src, dst  string
+		wantStart int
+		wantEnd   int
+		wantText  string
+	}{
+		{name: "insertion at the start", src: "package a\n", dst: "// h\n\npackage a\n", wantStart: 0, wantEnd: 0, wantText: "// h\n\n"},
+		{name: "replacement", src: "// a\npackage a\n", dst: "// b\npackage a\n", wantStart: 3, wantEnd: 4, wantText: "b"},
+		{name: "no changes", src: "package a\n", dst: "package a\n", wantStart: 10, wantEnd: 10, wantText: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, text := diff([]byte(tt.src), []byte(tt.dst))
+			if start != tt.wantStart || end != tt.wantEnd || string(text) != tt.wantText {
+				t.Errorf("diff() = %d, %d, %q, want %d, %d, %q", start, end, text, tt.wantStart, tt.wantEnd, tt.wantText)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+// go-licenser:ignore generated code
+
+package a
+
+func ignored() {}
@@ -0,0 +1,5 @@
+// go-licenser:licence=MIT // want "invalid go-licenser directive: line 1: unknown directive go-licenser:licence=MIT"
+
+package a
+
+func invalid() {}
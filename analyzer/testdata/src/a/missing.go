@@ -0,0 +1,3 @@
+package a // want "file is missing the ASL2 license header"
+
+func missing() {}
@@ -0,0 +1,193 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var archiveUsageText = `
+Usage: go-licenser archive [flags] <archive>
+
+  go-licenser archive checks the files of a .tar, .tar.gz, .tgz or .zip archive, like a release
+  artifact or a Go module zip, with the same rules as the files of a tree. The findings are
+  reported as archive!path/inside, the archive itself is never rewritten.
+
+Options:
+
+`[1:]
+
+// archiveCommand parses the archive flags from args and checks the archive.
+func archiveCommand(args []string, out io.Writer) error {
+	var opts options
+	var exclude sliceFlag
+	var timeout time.Duration
+	var fs = flag.NewFlagSet("archive", flag.ExitOnError)
+	fs.Var(&exclude, "exclude", `path inside the archive to exclude (can be specified multiple times).`)
+	fs.StringVar(&opts.ext, "ext", defaultExt, "sets the file extension to check.")
+	fs.StringVar(&opts.license, "license", defaultLicense, "sets the license type to check.")
+	fs.StringVar(&opts.licensor, "licensor", defaultLicensor, "sets the name of the licensor")
+	fs.StringVar(&opts.format, "format", defaultFormat, fmt.Sprintf("sets the report format: %s", strings.Join(reportFormats, ", ")))
+	fs.StringVar(&opts.foreignCopyright, "foreign-copyright", foreignReplace, fmt.Sprintf("sets how the headers holding the copyright notice of a third party are handled: %s", strings.Join(foreignModes, ", ")))
+	fs.BoolVar(&opts.optOuts, "opt-outs", false, "reports the files opting out of the license header with a go-licenser directive.")
+	fs.BoolVar(&opts.licenseFiles, "license-files", false, "checks that the LICENSE files in the archive match the license type.")
+	fs.BoolVar(&opts.summary, "summary", false, "prints a summary of the run with the counts per outcome, license and top-level directory.")
+	fs.BoolVar(&opts.failFast, "fail-fast", false, "stops at the first file which fails the check or can't be processed.")
+	fs.DurationVar(&timeout, "timeout", 0, "stops processing new files once the duration has elapsed, 0 means no timeout.")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), archiveUsageText)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	opts.exclude = exclude
+
+	ctx, stop := runContext(timeout)
+	defer stop()
+
+	return runArchive(ctx, fs.Arg(0), opts, out)
+}
+
+// runArchive checks the files of the archive found in path. The archive is
+// read-only, so its files are only ever checked.
+func runArchive(ctx context.Context, path string, opts options, out io.Writer) error {
+	t, closeArchive, err := openArchive(path)
+	if err != nil {
+		return err
+	}
+	defer closeArchive()
+
+	opts.dry = true
+	return runTree(ctx, t, opts, out)
+}
+
+// openArchive opens the archive found in path, by its extension, and returns
+// the tree of its files along with the function which releases it. The tree
+// of a Go module zip is rooted at its <module>@<version> directory.
+func openArchive(path string) (tree, func() error, error) {
+	if _, err := os.Stat(path); err != nil {
+		return tree{}, nil, &Error{err: err, code: exitFailedToStatTree}
+	}
+
+	var t = tree{root: ".", base: path, sep: archiveSep}
+	switch {
+	case strings.HasSuffix(path, ".zip"):
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return tree{}, nil, &Error{err: fmt.Errorf("%s: %w", path, err), code: errFailedReadingArchive}
+		}
+		t.fsys, t.root = zr, moduleRoot(zr.File)
+		return t, zr.Close, nil
+	case strings.HasSuffix(path, ".tar"):
+		f, err := os.Open(path)
+		if err != nil {
+			return tree{}, nil, &Error{err: err, code: errFailedReadingArchive}
+		}
+		if t.fsys, err = newTarFS(f); err != nil {
+			f.Close()
+			return tree{}, nil, &Error{err: fmt.Errorf("%s: %w", path, err), code: errFailedReadingArchive}
+		}
+		return t, f.Close, nil
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		f, err := gunzip(path)
+		if err != nil {
+			return tree{}, nil, &Error{err: fmt.Errorf("%s: %w", path, err), code: errFailedReadingArchive}
+		}
+		var release = func() error {
+			defer os.Remove(f.Name())
+			return f.Close()
+		}
+		if t.fsys, err = newTarFS(f); err != nil {
+			release()
+			return tree{}, nil, &Error{err: fmt.Errorf("%s: %w", path, err), code: errFailedReadingArchive}
+		}
+		return t, release, nil
+	}
+
+	return tree{}, nil, &Error{
+		err:  fmt.Errorf("%s: unsupported archive, expected a .tar, .tar.gz, .tgz or .zip file", path),
+		code: errFailedReadingArchive,
+	}
+}
+
+// gunzip decompresses the file found in path into a temporary file, so that
+// the files of the archive can be read in place, and returns it positioned at
+// its start. The caller removes the file.
+func gunzip(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "go-licenser-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(tmp, zr); err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// moduleRoot returns the <module>@<version> directory which holds all the
+// files of a Go module zip, or "." when the zip isn't a module zip.
+func moduleRoot(files []*zip.File) string {
+	if len(files) == 0 {
+		return "."
+	}
+
+	var name = files[0].Name
+	var at = strings.Index(name, "@")
+	if at < 0 {
+		return "."
+	}
+	var slash = strings.Index(name[at:], "/")
+	if slash < 0 {
+		return "."
+	}
+
+	var prefix = name[:at+slash+1]
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name, prefix) {
+			return "."
+		}
+	}
+	return strings.TrimSuffix(prefix, "/")
+}
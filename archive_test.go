@@ -0,0 +1,289 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+// writeArchive writes the files to an archive in path, whose format depends
+// on its extension.
+func writeArchive(t *testing.T, path string, files map[string]string) {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	switch filepath.Ext(path) {
+	case ".zip":
+		var zw = zip.NewWriter(&buf)
+		for _, name := range names {
+			w, err := zw.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(w, files[name])
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		var w io.Writer = &buf
+		var zw *gzip.Writer
+		if filepath.Ext(path) == ".gz" {
+			zw = gzip.NewWriter(&buf)
+			w = zw
+		}
+		var tw = tar.NewWriter(w)
+		for _, name := range names {
+			var hdr = tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+			if name[len(name)-1] == '/' {
+				hdr = tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeDir}
+			}
+			if err := tw.WriteHeader(&hdr); err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(tw, files[name])
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if zw != nil {
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_runArchive(t *testing.T) {
+	header, err := renderHeader(options{license: defaultLicense, licensor: defaultLicensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files = map[string]string{
+		"./main.go":            string(header) + "\npackage main\n",
+		"cmd/":                 "",
+		"cmd/tool/main.go":     "package main\n",
+		"vendor/dep/dep.go":    "package dep\n",
+		"internal/gen/gen.go":  "package gen\n",
+		"internal/gen/README":  "generated\n",
+		"internal/ok/ok.go":    string(header) + "\npackage ok\n",
+		"internal/ok/doc.go":   "// go-licenser:ignore third party\npackage ok\n",
+		"internal/ok/blob.go":  "\x00\x01",
+		"internal/ok/utf8.go":  string(header) + "\n// é\npackage ok\n",
+		"internal/ok/empty.go": "",
+	}
+
+	tests := []struct {
+		name       string
+		archive    string
+		files      map[string]string
+		wantOutput string
+	}{
+		{
+			name:    "tar",
+			archive: "release.tar",
+			files:   files,
+			wantOutput: `
+release.tar!cmd/tool/main.go: is missing the license header
+release.tar!internal/ok/blob.go: is skipped as a binary file, it contains NUL bytes
+release.tar!internal/ok/empty.go: is missing the license header
+`[1:],
+		},
+		{
+			name:    "tar.gz",
+			archive: "release.tar.gz",
+			files:   files,
+			wantOutput: `
+release.tar.gz!cmd/tool/main.go: is missing the license header
+release.tar.gz!internal/ok/blob.go: is skipped as a binary file, it contains NUL bytes
+release.tar.gz!internal/ok/empty.go: is missing the license header
+`[1:],
+		},
+		{
+			name:    "zip",
+			archive: "release.zip",
+			files: map[string]string{
+				"main.go":             files["./main.go"],
+				"cmd/tool/main.go":    files["cmd/tool/main.go"],
+				"internal/gen/gen.go": files["internal/gen/gen.go"],
+			},
+			wantOutput: `
+release.zip!cmd/tool/main.go: is missing the license header
+`[1:],
+		},
+		{
+			name:    "Module zip",
+			archive: "v1.0.0.zip",
+			files: map[string]string{
+				"example.com/mod@v1.0.0/main.go":             files["./main.go"],
+				"example.com/mod@v1.0.0/cmd/tool/main.go":    files["cmd/tool/main.go"],
+				"example.com/mod@v1.0.0/internal/gen/gen.go": files["internal/gen/gen.go"],
+			},
+			wantOutput: `
+v1.0.0.zip!example.com/mod@v1.0.0/cmd/tool/main.go: is missing the license header
+`[1:],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dir = t.TempDir()
+			var path = filepath.Join(dir, tt.archive)
+			writeArchive(t, path, tt.files)
+
+			var buf = new(bytes.Buffer)
+			var err = runArchive(context.Background(), path, options{
+				license:  defaultLicense,
+				licensor: defaultLicensor,
+				exclude:  []string{"internal/gen"},
+				ext:      defaultExt,
+				pathBase: dir,
+			}, buf)
+			if got := Code(err); got != exitSourceNeedsToBeRewritten {
+				t.Errorf("runArchive() = %v, want %v", err, exitSourceNeedsToBeRewritten)
+			}
+			if got := buf.String(); got != tt.wantOutput {
+				t.Errorf("Output = \n%v\n want \n%v", got, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func Test_runArchive_summary(t *testing.T) {
+	var dir = t.TempDir()
+	var path = filepath.Join(dir, "v1.0.0.zip")
+	writeArchive(t, path, map[string]string{
+		"example.com/mod@v1.0.0/main.go":     "package main\n",
+		"example.com/mod@v1.0.0/cmd/main.go": "package main\n",
+		"example.com/mod@v1.0.0/LICENSE":     testElasticText,
+	})
+
+	var buf = new(bytes.Buffer)
+	var err = runArchive(context.Background(), path, options{
+		license:      defaultLicense,
+		licensor:     defaultLicensor,
+		ext:          defaultExt,
+		pathBase:     dir,
+		licenseFiles: true,
+		summary:      true,
+		format:       formatJSON,
+	}, buf)
+	if got := Code(err); got != exitLicenseFileMismatch {
+		t.Errorf("runArchive() = %v, want %v", err, exitLicenseFileMismatch)
+	}
+
+	var doc struct {
+		Findings []finding `json:"findings"`
+		Summary  summary   `json:"summary"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	var wantFindings = []finding{
+		{Path: "v1.0.0.zip!example.com/mod@v1.0.0/cmd/main.go", Kind: findingMissingHeader, Message: "is missing the license header"},
+		{Path: "v1.0.0.zip!example.com/mod@v1.0.0/main.go", Kind: findingMissingHeader, Message: "is missing the license header"},
+		{Path: "v1.0.0.zip!example.com/mod@v1.0.0/LICENSE", Kind: findingLicenseFileMismatch, Message: "is licensed under Elastic-2.0 but the headers are Apache-2.0"},
+	}
+	if !reflect.DeepEqual(doc.Findings, wantFindings) {
+		t.Errorf("Findings = %+v, want %+v", doc.Findings, wantFindings)
+	}
+
+	var wantDirectories = map[string]*counts{
+		".":   {Failing: 1},
+		"cmd": {Failing: 1},
+	}
+	if !reflect.DeepEqual(doc.Summary.Directories, wantDirectories) {
+		t.Errorf("Directories = %+v, want %+v", doc.Summary.Directories, wantDirectories)
+	}
+}
+
+func Test_openArchive(t *testing.T) {
+	var dir = t.TempDir()
+	var unknown = filepath.Join(dir, "release.rar")
+	if err := os.WriteFile(unknown, []byte("rar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var corrupt = filepath.Join(dir, "release.tar.gz")
+	if err := os.WriteFile(corrupt, []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "Missing archive", path: filepath.Join(dir, "missing.zip"), want: exitFailedToStatTree},
+		{name: "Unsupported archive", path: unknown, want: errFailedReadingArchive},
+		{name: "Corrupt archive", path: corrupt, want: errFailedReadingArchive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := openArchive(tt.path); Code(err) != tt.want {
+				t.Errorf("openArchive() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_tarFS(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "release.tar")
+	writeArchive(t, path, map[string]string{
+		"./main.go":         "package main\n",
+		"/abs.go":           "package main\n",
+		"cmd/":              "",
+		"cmd/tool/main.go":  "package main\n",
+		"internal/x/x.go":   "package x\n",
+		"internal/x/y.go":   "package x\n",
+		"../outside/bad.go": "package bad\n",
+	})
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fsys, err := newTarFS(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "main.go", "abs.go", "cmd/tool/main.go", "internal/x/x.go", "internal/x/y.go"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,69 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+// writeFileAtomic writes the data to the file found in path, atomically like
+// the files rewritten through licensing.DirFS, so that readers, or an
+// interrupted run, never see a partially written file.
+func writeFileAtomic(path string, data []byte, perm fs.FileMode) error {
+	return licensing.DirFS(filepath.Dir(path)).WriteFile(filepath.Base(path), perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// readPreamble reads the preamble of the file name of the tree, the part at
+// its top which holds the header.
+func readPreamble(t tree, name string) ([]byte, error) {
+	f, err := t.fsys.Open(name)
+	if err != nil {
+		return nil, &Error{err: err, code: exitFailedToOpenWalkFile}
+	}
+	defer f.Close()
+
+	preamble, err := licensing.ReadPreamble(f)
+	if err != nil {
+		return nil, &Error{err: err, code: exitFailedToOpenWalkFile}
+	}
+	return preamble, nil
+}
+
+// rewritePreamble replaces the preamble of the file name of the tree, which
+// was read by readPreamble, with fixed. The rest of the file is copied as is.
+func rewritePreamble(t tree, name string, preamble, fixed []byte) error {
+	var err = licensing.RewriteFileFS(t.fsys, name, func(current []byte) ([]byte, error) {
+		if !bytes.Equal(current, preamble) {
+			return nil, fmt.Errorf("%s: changed while being rewritten", t.path(name))
+		}
+		return fixed, nil
+	})
+	if err != nil {
+		return &Error{err: err, code: errFailedRewrittingFile}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkCache records the files which were found to contain the expected
+// header, so that they can be skipped while they remain unchanged. A cache is
+// only valid for the configuration it was created with, any change to the
+// header or the options discards all of its entries.
+type checkCache struct {
+	path  string
+	dirty bool
+	seen  map[string]bool
+
+	Config  string                `json:"config"`
+	Entries map[string]cacheEntry `json:"entries"`
+}
+
+type cacheEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"hash"`
+	Checked time.Time `json:"checked"`
+}
+
+// modTimeGranularity is the coarsest resolution of the modification times of
+// the supported filesystems. A file modified within that time of being checked
+// can change again without its modification time changing.
+const modTimeGranularity = 2 * time.Second
+
+// defaultCacheDir returns the directory where the caches are stored when no
+// directory is specified.
+func defaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-licenser"), nil
+}
+
+// configHash returns a hash of everything that affects the result of checking
+// a file: the header which is written, the options used to render it and all
+// the supported license headers along with their variants.
+func configHash(headerBytes []byte, opts options) string {
+	var h = sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%t\x00", version, opts.license, opts.licensor, opts.copyright)
+	h.Write(headerBytes)
+
+	var registry = opts.licenses()
+	for _, k := range registry.Keys() {
+		fmt.Fprintf(h, "\x00%s", k)
+		lines, _ := registry.Lookup(k)
+		for _, line := range lines {
+			fmt.Fprintf(h, "\x00%s", line)
+		}
+		for _, v := range registry.Variants(k) {
+			fmt.Fprintf(h, "\x00%s", v.Name)
+			for _, line := range v.Lines {
+				fmt.Fprintf(h, "\x00%s", line)
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newRunCache opens the cache for the tree and header used by run.
+func newRunCache(root string, headerBytes []byte, opts options) (*checkCache, error) {
+	var dir = opts.cacheDir
+	if dir == "" {
+		var err error
+		if dir, err = defaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	return openCache(dir, root, configHash(headerBytes, opts))
+}
+
+// openCache opens the cache for the root path in dir. When the cache doesn't
+// exist, can't be decoded or was created with another configuration, an
+// empty cache is returned.
+func openCache(dir, root, config string) (*checkCache, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var sum = sha256.Sum256([]byte(abs))
+	var c = checkCache{
+		path: filepath.Join(dir, hex.EncodeToString(sum[:8])+".json"),
+		seen: make(map[string]bool),
+	}
+
+	if b, err := os.ReadFile(c.path); err == nil {
+		if json.Unmarshal(b, &c) != nil || c.Config != config {
+			c.Entries = nil
+		}
+	}
+
+	if c.Entries == nil {
+		c.Entries = make(map[string]cacheEntry)
+		c.dirty = true
+	}
+	c.Config = config
+
+	return &c, nil
+}
+
+// Compliant returns true when the file was found to contain the expected
+// header and hasn't changed since. Files which have been modified but whose
+// contents are the same are still considered compliant. The modification time
+// is only trusted when the file was checked long enough after it was last
+// modified, otherwise the contents are hashed again.
+func (c *checkCache) Compliant(t tree, name string, info fs.FileInfo) bool {
+	var key = cacheKey(t.path(name))
+	c.seen[key] = true
+	entry, ok := c.Entries[key]
+	if !ok || entry.Size != info.Size() {
+		return false
+	}
+
+	if entry.ModTime.Equal(info.ModTime()) && entry.ModTime.Before(entry.Checked.Add(-modTimeGranularity)) {
+		return true
+	}
+
+	hash, err := hashFile(t.fsys, name)
+	if err != nil || hash != entry.Hash {
+		return false
+	}
+
+	entry.ModTime, entry.Checked = info.ModTime(), time.Now()
+	c.Entries[key] = entry
+	c.dirty = true
+	return true
+}
+
+// Store records the file name of the tree as containing the expected header.
+func (c *checkCache) Store(t tree, name string, info fs.FileInfo) error {
+	hash, err := hashFile(t.fsys, name)
+	if err != nil {
+		return err
+	}
+
+	var key = cacheKey(t.path(name))
+	c.seen[key] = true
+	c.Entries[key] = cacheEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Hash:    hash,
+		Checked: time.Now(),
+	}
+	c.dirty = true
+	return nil
+}
+
+// Prune drops the entries of the files which haven't been looked up since the
+// cache was opened, such as deleted or excluded files. It must only be called
+// once the whole tree has been walked.
+func (c *checkCache) Prune() {
+	for key := range c.Entries {
+		if !c.seen[key] {
+			delete(c.Entries, key)
+			c.dirty = true
+		}
+	}
+}
+
+// Save writes the cache to disk when it has been modified.
+func (c *checkCache) Save() error {
+	if !c.dirty {
+		return nil
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that concurrent runs never read a
+	// partially written cache.
+	if err := writeFileAtomic(c.path, b, 0644); err != nil {
+		return err
+	}
+
+	c.dirty = false
+	return nil
+}
+
+func cacheKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func hashFile(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var h = sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
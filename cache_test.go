@@ -0,0 +1,219 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+func Test_checkCache(t *testing.T) {
+	var dir, root = t.TempDir(), t.TempDir()
+	var path, tr = filepath.Join(root, "file.go"), osTree(root)
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var compliant = func(c *checkCache) bool {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.Compliant(tr, "file.go", info)
+	}
+
+	c, err := openCache(dir, root, "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compliant(c) {
+		t.Error("unknown file is compliant")
+	}
+
+	info, _ := os.Stat(path)
+	if err := c.Store(tr, "file.go", info); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, _ = openCache(dir, root, "config"); !compliant(c) {
+		t.Error("stored file isn't compliant after reopening the cache")
+	}
+
+	var later = info.ModTime().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if !compliant(c) {
+		t.Error("touched file with the same contents isn't compliant")
+	}
+
+	if err := os.WriteFile(path, []byte("package test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if compliant(c) {
+		t.Error("modified file is compliant")
+	}
+
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !compliant(c) {
+		t.Error("restored file isn't compliant")
+	}
+
+	// A change within the granularity of the modification times of a file
+	// which was just checked is found, even if its size and modification time
+	// haven't changed.
+	info, _ = os.Stat(path)
+	if err := c.Store(tr, "file.go", info); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("package mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if compliant(c) {
+		t.Error("racily modified file is compliant")
+	}
+
+	// The entries of the files which weren't looked up are pruned.
+	c.Entries[cacheKey(filepath.Join(root, "deleted.go"))] = cacheEntry{}
+	c.Prune()
+	if _, ok := c.Entries[cacheKey(filepath.Join(root, "deleted.go"))]; ok || len(c.Entries) != 1 {
+		t.Errorf("Prune() entries = %v", c.Entries)
+	}
+
+	if c, _ = openCache(dir, root, "another config"); compliant(c) {
+		t.Error("cache isn't invalidated when the configuration changes")
+	}
+}
+
+// registerHeader replaces licensing.Default with a copy holding the header
+// of an additional license until the test ends.
+func registerHeader(t *testing.T, key string, lines []string) {
+	var registry = licensing.NewRegistry()
+	for _, k := range licensing.Default.Keys() {
+		template, _ := licensing.Default.Lookup(k)
+		if err := registry.Register(k, template); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := registry.Register(key, lines); err != nil {
+		t.Fatal(err)
+	}
+
+	var previous = licensing.Default
+	licensing.Default = registry
+	t.Cleanup(func() { licensing.Default = previous })
+}
+
+func Test_configHash(t *testing.T) {
+	var opts = options{license: defaultLicense, licensor: defaultLicensor}
+	var header = []byte("// header\n")
+	var want = configHash(header, opts)
+
+	if got := configHash(header, opts); got != want {
+		t.Errorf("configHash() = %s, want %s", got, want)
+	}
+
+	if configHash([]byte("// another header\n"), opts) == want {
+		t.Error("configHash() doesn't change with the header")
+	}
+
+	var other = opts
+	other.licensor = "Someone Else"
+	if configHash(header, other) == want {
+		t.Error("configHash() doesn't change with the licensor")
+	}
+
+	registerHeader(t, "Test", []string{"// test"})
+	if configHash(header, opts) == want {
+		t.Error("configHash() doesn't change with the supported headers")
+	}
+}
+
+func Test_run_cache(t *testing.T) {
+	defer copyFixtures(t, "testdata")()
+
+	var opts = options{
+		license:  defaultLicense,
+		licensor: defaultLicensor,
+		exclude:  []string{"excludedpath"},
+		ext:      defaultExt,
+		cache:    true,
+		cacheDir: t.TempDir(),
+	}
+
+	if err := run(context.Background(), []string{"testdata"}, opts, new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The modification time of the files rewritten right before being checked
+	// isn't trusted, make it old enough for the cached result to be used.
+	var path = filepath.Join("testdata", "singlelevel", "main.go")
+	var old = time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.dry = true
+	if err := run(context.Background(), []string{"testdata"}, opts, new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Break the header without changing the size or modification time of the
+	// file, the cached result must be used.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, bytes.Replace(contents, []byte("// Licensed"), []byte("// licensed"), 1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf = new(bytes.Buffer)
+	if err := run(context.Background(), []string{"testdata"}, opts, buf); err != nil || buf.Len() > 0 {
+		t.Fatalf("run() = %v, %q with an unchanged file", err, buf.String())
+	}
+
+	// Changing the supported headers invalidates the cache.
+	registerHeader(t, "Test", []string{"// test"})
+
+	var want = filepath.FromSlash("testdata/singlelevel/main.go: is missing the license header\n")
+	if err := run(context.Background(), []string{"testdata"}, opts, buf); Code(err) != exitSourceNeedsToBeRewritten || buf.String() != want {
+		t.Errorf("run() = %v, %q after the headers changed", err, buf.String())
+	}
+}
@@ -0,0 +1,318 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+var compareUsageText = `
+Usage: go-licenser compare [flags] <base> [<head>]
+
+  go-licenser compare evaluates the license headers of two git revisions of the repository of the
+  -repo path, or of two directories with -dirs, and only reports the files whose status got worse
+  in head: new files missing the license header, and headers which were removed or changed to
+  another license. Files which were already failing in base aren't reported, so that trees with
+  existing violations can gate their changes. Without head, the revision is compared to the
+  working tree. The files are never rewritten.
+
+Options:
+
+`[1:]
+
+// Finding kinds of the regressions, along with findingMissingHeader for the
+// new files missing the header.
+const (
+	findingRemovedHeader  = "removed-header"
+	findingChangedLicense = "changed-license"
+)
+
+// compareOptions are the settings of a comparison between two trees.
+type compareOptions struct {
+	options
+	repo string
+	dirs bool
+}
+
+// compareCommand parses the compare flags from args and compares the trees.
+func compareCommand(args []string, out io.Writer) error {
+	var opts compareOptions
+	var exclude sliceFlag
+	var timeout time.Duration
+	var fs = flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Var(&exclude, "exclude", `path to exclude (can be specified multiple times).`)
+	fs.StringVar(&opts.ext, "ext", defaultExt, "sets the file extension to check.")
+	fs.StringVar(&opts.license, "license", defaultLicense, "sets the license type to check.")
+	fs.StringVar(&opts.licensor, "licensor", defaultLicensor, "sets the name of the licensor")
+	fs.StringVar(&opts.format, "format", defaultFormat, fmt.Sprintf("sets the report format: %s", strings.Join(reportFormats, ", ")))
+	fs.StringVar(&opts.repo, "repo", defaultPath, "sets the path of the repository, or of the directory of the repository to compare.")
+	fs.BoolVar(&opts.dirs, "dirs", false, "compares two directories instead of two git revisions.")
+	fs.DurationVar(&timeout, "timeout", 0, "stops processing new files once the duration has elapsed, 0 means no timeout.")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), compareUsageText)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 || (opts.dirs && fs.NArg() != 2) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	opts.exclude = exclude
+
+	ctx, stop := runContext(timeout)
+	defer stop()
+
+	return runCompare(ctx, fs.Args(), opts, out)
+}
+
+// runCompare compares the trees of the base and head arguments, which are
+// directories or git revisions, and reports the regressions of head.
+func runCompare(ctx context.Context, args []string, opts compareOptions, out io.Writer) error {
+	var trees []tree
+	for _, arg := range args {
+		if opts.dirs {
+			trees = append(trees, osTree(arg))
+			continue
+		}
+
+		t, closeRepo, err := revTree(opts.repo, arg)
+		if err != nil {
+			return err
+		}
+		defer closeRepo()
+		trees = append(trees, t)
+	}
+	if len(trees) == 1 {
+		trees = append(trees, osTree(opts.repo))
+	}
+
+	return compareTrees(ctx, trees[0], trees[1], opts.options, out)
+}
+
+// fileState is the license status of a file, which the comparison tracks.
+type fileState struct {
+	// path is the path which the file is reported as.
+	path string
+
+	// license is the key of the license of the registry whose header starts
+	// the file, or is empty when the file has none of them.
+	license string
+
+	// expected is the license of the header the file must carry, which is
+	// the license of its go-licenser:license directive when it has one.
+	expected string
+
+	// compliant is true when the file carries the expected header or opted
+	// out of the license header.
+	compliant bool
+
+	// preamble is the hash of the preamble of the file, by which moved files
+	// are matched.
+	preamble [sha256.Size]byte
+}
+
+// compareTrees reports the files of head whose license status got worse
+// than in base.
+func compareTrees(ctx context.Context, base, head tree, opts options, out io.Writer) error {
+	if _, err := renderHeader(opts); err != nil {
+		return err
+	}
+
+	r, err := newReporter(opts.format, out)
+	if err != nil {
+		return &Error{err: err, code: errUnknownFormat}
+	}
+	if r, err = newPathReporter(r, "", false, base, head); err != nil {
+		return &Error{err: err, code: exitFailedToStatTree}
+	}
+
+	var errs Errors
+	var states = make([]map[string]fileState, 2)
+	for i, t := range []tree{base, head} {
+		// The directory of a revision may not exist in the other one, where
+		// it has no files.
+		if _, err := fs.Stat(t.fsys, t.root); err != nil {
+			if t.sep == revSep && errors.Is(err, fs.ErrNotExist) {
+				states[i] = make(map[string]fileState)
+				continue
+			}
+			return &Error{err: err, code: exitFailedToStatTree}
+		}
+		states[i] = treeStates(ctx, t, opts, &errs)
+	}
+	var before, after = states[0], states[1]
+
+	// The files of base which aren't in head anymore may have been moved,
+	// the new files with the same preamble are left alone.
+	var removed = make(map[[sha256.Size]byte]bool)
+	for name, s := range before {
+		if _, ok := after[name]; !ok {
+			removed[s.preamble] = true
+		}
+	}
+
+	var names = make([]string, 0, len(after))
+	for name := range after {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var s = after[name]
+		b, ok := before[name]
+		if !ok && removed[s.preamble] {
+			continue
+		}
+		if kind, message, regressed := regression(b, ok, s); regressed {
+			reportFile(r, s.path, kind, message)
+			errs.Add(&Error{code: exitSourceNeedsToBeRewritten})
+		}
+	}
+
+	errs.Add(r.Flush())
+	return errs.Err()
+}
+
+// regression returns the finding describing how the license status of a file
+// got worse from base to head. The base state is only set when the file was
+// found in base.
+func regression(base fileState, inBase bool, head fileState) (kind, message string, regressed bool) {
+	switch {
+	case head.compliant:
+		return "", "", false
+	case !inBase && head.license == "":
+		return findingMissingHeader, "is a new file missing the license header", true
+	case !inBase:
+		return findingChangedLicense, fmt.Sprintf("is a new file with the %s license header instead of %s", head.license, head.expected), true
+	case base.license != "" && head.license == "":
+		return findingRemovedHeader, fmt.Sprintf("had the %s license header, which was removed", base.license), true
+	case base.license != "" && head.license != base.license:
+		return findingChangedLicense, fmt.Sprintf("had the %s license header, which was changed to %s", base.license, head.license), true
+	case base.compliant && head.license == "":
+		return findingMissingHeader, fmt.Sprintf("was compliant and is now missing the %s license header", head.expected), true
+	case base.compliant:
+		return findingChangedLicense, fmt.Sprintf("was compliant and now has the %s license header instead of %s", head.license, head.expected), true
+	}
+	return "", "", false
+}
+
+// treeStates returns the license status of the files of the tree, by their
+// name relative to its root. The files are walked with the same rules as a
+// run, until the context is done. The errors are added to errs.
+func treeStates(ctx context.Context, t tree, opts options, errs *Errors) map[string]fileState {
+	var states = make(map[string]fileState)
+	var excluded = newExclusions(t.path(t.root), opts.exclude)
+	fs.WalkDir(t.fsys, t.root, func(name string, info fs.DirEntry, walkErr error) error {
+		if err := ctx.Err(); err != nil {
+			errs.Add(cancelled(err))
+			return fs.SkipAll
+		}
+		if walkErr != nil {
+			errs.Add(&Error{err: walkErr, code: exitFailedToWalkPath})
+			return nil
+		}
+
+		var excludedDir = name != t.root && info.IsDir() && stringInSlice(info.Name(), defaultExludedDirs)
+		if t.excluded(excluded, name) || excludedDir {
+			if info.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || filepath.Ext(name) != opts.ext {
+			return nil
+		}
+
+		s, ok, err := readFileState(t, name, opts)
+		if err != nil {
+			errs.Add(err)
+			return nil
+		}
+		if ok {
+			states[relName(t, name)] = s
+		}
+		return nil
+	})
+	return states
+}
+
+// readFileState returns the license status of the file name of the tree. The
+// binary and UTF-16 files, which can't carry the header, aren't tracked.
+func readFileState(t tree, name string, opts options) (fileState, bool, error) {
+	preamble, err := readPreamble(t, name)
+	if err != nil {
+		return fileState{}, false, err
+	}
+	if _, binary := licensing.IsBinary(preamble); binary || licensing.IsUTF16(preamble) {
+		return fileState{}, false, nil
+	}
+
+	var s = fileState{
+		path:     t.path(name),
+		license:  headerLicense(preamble, opts),
+		expected: opts.license,
+		preamble: sha256.Sum256(preamble),
+	}
+	s.compliant = s.license == s.expected
+
+	if d := licensing.ParseDirectives(bytes.NewReader(preamble)); d.Present() {
+		switch {
+		case len(d.Problems) > 0:
+			s.compliant = false
+		case d.Ignore:
+			s.compliant = true
+		case d.License != "":
+			s.expected = d.License
+			s.compliant = s.license == d.License
+			// The licenses which the registry has no header for are
+			// trusted unless the header is of another license.
+			if _, ok := opts.licenses().Lookup(d.License); !ok {
+				_, contradicted := contradictedLicense(preamble, d.License)
+				s.compliant = !contradicted
+			}
+		}
+	}
+	return s, true, nil
+}
+
+// relName returns the slash separated name of the file name of the tree
+// relative to its root, which matches the files of two trees.
+func relName(t tree, name string) string {
+	if t.root == "." {
+		return name
+	}
+	if name == t.root {
+		return path.Base(name)
+	}
+	return strings.TrimPrefix(name, t.root+"/")
+}
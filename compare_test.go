@@ -0,0 +1,291 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree writes the files to a new temporary directory and returns it.
+func writeTree(t *testing.T, files map[string]string) string {
+	var dir = t.TempDir()
+	writeFiles(t, dir, files)
+	return dir
+}
+
+func Test_compareTrees(t *testing.T) {
+	var header = func(license string) string {
+		h, err := renderHeader(options{license: license, licensor: defaultLicensor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(h) + "\n"
+	}
+	var asl2, elastic = header("ASL2"), header("Elastic")
+	var legacy = map[string]string{
+		"main.go":        asl2 + "package main\n",
+		"legacy/old.go":  "package legacy\n",
+		"vendor/dep.go":  "package dep\n",
+		"other/other.go": elastic + "package other\n",
+	}
+	var with = func(files map[string]string) map[string]string {
+		var merged = make(map[string]string)
+		for name, contents := range legacy {
+			merged[name] = contents
+		}
+		for name, contents := range files {
+			if contents == "" {
+				delete(merged, name)
+				continue
+			}
+			merged[name] = contents
+		}
+		return merged
+	}
+
+	tests := []struct {
+		name string
+		head map[string]string
+		opts options
+		want []finding
+		code int
+	}{
+		{name: "Unchanged tree with violations", head: legacy},
+		{
+			name: "Fixed and edited files",
+			head: with(map[string]string{
+				"legacy/old.go":  asl2 + "package legacy\n",
+				"other/other.go": elastic + "package other\n\nfunc Other() {}\n",
+			}),
+		},
+		{
+			name: "New compliant and opted out files",
+			head: with(map[string]string{
+				"new.go":      asl2 + "package main\n",
+				"gen/gen.go":  "// go-licenser:ignore generated\n\npackage gen\n",
+				"third/x.go":  elastic + "// go-licenser:license=Elastic\n\npackage third\n",
+				"bsd/x.go":    testGoBSDHeader + "// go-licenser:license=BSD-3-Clause\n\npackage bsd\n",
+				"vendor/x.go": "package dep\n",
+				"README.md":   "readme\n",
+			}),
+		},
+		{
+			name: "Moved file with a violation",
+			head: with(map[string]string{
+				"legacy/old.go":   "",
+				"renamed/main.go": "package legacy\n",
+			}),
+		},
+		{
+			name: "New file missing the header",
+			head: with(map[string]string{"cmd/tool/main.go": "package main\n"}),
+			want: []finding{{Path: "cmd/tool/main.go", Kind: findingMissingHeader, Message: "is a new file missing the license header"}},
+			code: exitSourceNeedsToBeRewritten,
+		},
+		{
+			name: "New file with another license",
+			head: with(map[string]string{"new.go": elastic + "package main\n"}),
+			want: []finding{{Path: "new.go", Kind: findingChangedLicense, Message: "is a new file with the Elastic license header instead of ASL2"}},
+			code: exitSourceNeedsToBeRewritten,
+		},
+		{
+			name: "Removed and changed headers",
+			head: with(map[string]string{
+				"main.go":        elastic + "package main\n",
+				"other/other.go": "package other\n",
+			}),
+			want: []finding{
+				{Path: "main.go", Kind: findingChangedLicense, Message: "had the ASL2 license header, which was changed to Elastic"},
+				{Path: "other/other.go", Kind: findingRemovedHeader, Message: "had the Elastic license header, which was removed"},
+			},
+			code: exitSourceNeedsToBeRewritten,
+		},
+		{
+			name: "Removed opt-out",
+			head: with(map[string]string{
+				"gen/gen.go": "package gen\n",
+			}),
+			want: []finding{{Path: "gen/gen.go", Kind: findingMissingHeader, Message: "is a new file missing the license header"}},
+			code: exitSourceNeedsToBeRewritten,
+		},
+		{
+			name: "Exclusions",
+			head: with(map[string]string{"cmd/tool/main.go": "package main\n"}),
+			opts: options{exclude: []string{"cmd"}},
+		},
+		{
+			name: "Other license type",
+			head: with(map[string]string{"new.go": asl2 + "package main\n"}),
+			opts: options{license: "Elastic"},
+			want: []finding{{Path: "new.go", Kind: findingChangedLicense, Message: "is a new file with the ASL2 license header instead of Elastic"}},
+			code: exitSourceNeedsToBeRewritten,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var base, head = writeTree(t, legacy), writeTree(t, tt.head)
+			var opts = tt.opts
+			opts.format, opts.ext, opts.licensor = formatJSON, defaultExt, defaultLicensor
+			if opts.license == "" {
+				opts.license = defaultLicense
+			}
+
+			var buf = new(bytes.Buffer)
+			var err = compareTrees(context.Background(), osTree(base), osTree(head), opts, buf)
+			if got := Code(err); got != tt.code {
+				t.Errorf("compareTrees() = %v, want %v", err, tt.code)
+			}
+
+			var doc struct{ Findings []finding }
+			if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			}
+			var want = []finding{}
+			wd, _ := os.Getwd()
+			for _, f := range tt.want {
+				f.Path = displayPath(wd, filepath.Join(head, filepath.FromSlash(f.Path)), false)
+				want = append(want, f)
+			}
+			if !reflect.DeepEqual(doc.Findings, want) {
+				t.Errorf("Findings = %+v, want %+v", doc.Findings, want)
+			}
+		})
+	}
+}
+
+func Test_regression(t *testing.T) {
+	var compliant = fileState{license: "ASL2", expected: "ASL2", compliant: true}
+	var missing = fileState{expected: "ASL2"}
+	var ignored = fileState{expected: "ASL2", compliant: true}
+
+	tests := []struct {
+		name      string
+		base      fileState
+		inBase    bool
+		head      fileState
+		wantKind  string
+		regressed bool
+	}{
+		{name: "Compliant", base: missing, inBase: true, head: compliant},
+		{name: "Still missing", base: missing, inBase: true, head: missing},
+		{name: "New compliant file", head: compliant},
+		{name: "New file missing the header", head: missing, wantKind: findingMissingHeader, regressed: true},
+		{name: "Removed header", base: compliant, inBase: true, head: missing, wantKind: findingRemovedHeader, regressed: true},
+		{
+			name:   "Header of the wrong license removed",
+			base:   fileState{license: "Elastic", expected: "ASL2"},
+			inBase: true, head: missing,
+			wantKind: findingRemovedHeader, regressed: true,
+		},
+		{
+			name:   "Changed license",
+			base:   compliant,
+			inBase: true, head: fileState{license: "Elastic", expected: "ASL2"},
+			wantKind: findingChangedLicense, regressed: true,
+		},
+		{
+			name:   "Directive removed",
+			base:   fileState{license: "Elastic", expected: "Elastic", compliant: true},
+			inBase: true, head: fileState{license: "Elastic", expected: "ASL2"},
+			wantKind: findingChangedLicense, regressed: true,
+		},
+		{name: "Opt-out removed", base: ignored, inBase: true, head: missing, wantKind: findingMissingHeader, regressed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, message, regressed := regression(tt.base, tt.inBase, tt.head)
+			if regressed != tt.regressed || kind != tt.wantKind {
+				t.Errorf("regression() = %q, %q, %v, want %q, %v", kind, message, regressed, tt.wantKind, tt.regressed)
+			}
+		})
+	}
+}
+
+func Test_runCompare_rev(t *testing.T) {
+	header, err := renderHeader(options{license: defaultLicense, licensor: defaultLicensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dir = gitRepo(t, nil)
+	gitCommit(t, dir, "base", map[string]string{
+		"main.go":          string(header) + "\npackage main\n",
+		"legacy/legacy.go": "package legacy\n",
+	})
+	gitCommit(t, dir, "head", map[string]string{
+		"main.go":       "package main\n",
+		"cmd/x/main.go": "package main\n",
+	})
+	// The working tree fixes the regressions of the head revision.
+	writeFiles(t, dir, map[string]string{
+		"main.go":       string(header) + "\npackage main\n",
+		"cmd/x/main.go": string(header) + "\npackage main\n",
+	})
+
+	tests := []struct {
+		name       string
+		args       []string
+		repo       string
+		want       int
+		wantOutput string
+	}{
+		{
+			name: "Two revisions",
+			args: []string{"HEAD~1", "HEAD"},
+			repo: dir,
+			want: exitSourceNeedsToBeRewritten,
+			wantOutput: `
+HEAD:cmd/x/main.go: is a new file missing the license header
+HEAD:main.go: had the ASL2 license header, which was removed
+`[1:],
+		},
+		{
+			name: "Subdirectory",
+			args: []string{"HEAD~1", "HEAD"},
+			repo: filepath.Join(dir, "cmd"),
+			want: exitSourceNeedsToBeRewritten,
+			wantOutput: `
+HEAD:cmd/x/main.go: is a new file missing the license header
+`[1:],
+		},
+		{name: "Reverted regressions", args: []string{"HEAD~1", "HEAD~1"}, repo: dir},
+		{name: "Working tree", args: []string{"HEAD~1"}, repo: dir},
+		{name: "Unknown revision", args: []string{"v1.0", "HEAD"}, repo: dir, want: errFailedReadingRevision},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf = new(bytes.Buffer)
+			var err = runCompare(context.Background(), tt.args, compareOptions{
+				options: options{license: defaultLicense, licensor: defaultLicensor, ext: defaultExt},
+				repo:    tt.repo,
+			}, buf)
+			if got := Code(err); got != tt.want {
+				t.Errorf("runCompare() = %v, want %v", err, tt.want)
+			}
+			if got := buf.String(); got != tt.wantOutput {
+				t.Errorf("Output = \n%v\n want \n%v", got, tt.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+const defaultPolicyFile = ".go-licenser-policy.json"
+
+var depsUsageText = `
+Usage: go-licenser deps [flags] [path]
+
+  go-licenser deps classifies the license of every module in the build list of the Go module
+  found in path and checks it against the allowed, denied and review-required licenses of a
+  policy file.
+
+Options:
+
+`[1:]
+
+// Policy verdicts.
+const (
+	verdictAllowed   = "allowed"
+	verdictDenied    = "denied"
+	verdictReview    = "review"
+	verdictUnknown   = "unknown"
+	verdictException = "exception"
+)
+
+// policy is the set of rules dependencies licenses are checked against. The
+// licenses are listed by their SPDX identifier.
+type policy struct {
+	Allow      []string          `json:"allow"`
+	Deny       []string          `json:"deny"`
+	Review     []string          `json:"review"`
+	Exceptions []policyException `json:"exceptions"`
+}
+
+// policyException allows a module regardless of its license. When Version is
+// empty, the exception applies to all the versions of the module.
+type policyException struct {
+	Module        string `json:"module"`
+	Version       string `json:"version,omitempty"`
+	Justification string `json:"justification"`
+}
+
+type depsOptions struct {
+	path   string
+	policy string
+	format string
+}
+
+// depsCommand parses the deps flags from args and runs the command.
+func depsCommand(args []string, out io.Writer) error {
+	var opts depsOptions
+	var fs = flag.NewFlagSet("deps", flag.ExitOnError)
+	fs.StringVar(&opts.policy, "policy", "", fmt.Sprintf("sets the policy file (default \"<path>/%s\").", defaultPolicyFile))
+	fs.StringVar(&opts.format, "format", defaultFormat, fmt.Sprintf("sets the report format: %s", strings.Join(reportFormats, ", ")))
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), depsUsageText)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	opts.path = defaultPath
+	if fs.NArg() > 0 {
+		opts.path = fs.Arg(0)
+	}
+
+	return deps(opts, out)
+}
+
+func deps(opts depsOptions, out io.Writer) error {
+	r, err := newReporter(opts.format, out)
+	if err != nil {
+		return &Error{err: err, code: errUnknownFormat}
+	}
+
+	if opts.policy == "" {
+		opts.policy = filepath.Join(opts.path, defaultPolicyFile)
+	}
+
+	p, err := readPolicy(opts.policy)
+	if err != nil {
+		return &Error{err: err, code: errInvalidPolicy}
+	}
+
+	_, modules, err := dependencies(opts.path)
+	if err != nil {
+		return &Error{err: err, code: errFailedToListModules}
+	}
+
+	var violation, unavailable bool
+	for _, m := range modules {
+		var name = m.Path + "@" + m.Version
+		id, _, err := moduleLicense(m.Dir)
+		if errors.Is(err, errSourcesUnavailable) {
+			if p.classify(m, id) != verdictException {
+				unavailable = true
+				r.Report(finding{Path: name, Kind: findingUnavailableSources, Message: err.Error()})
+			}
+			continue
+		}
+		if err != nil {
+			return &Error{err: err, code: errFailedToListModules}
+		}
+
+		switch p.classify(m, id) {
+		case verdictDenied:
+			violation = true
+			r.Report(finding{Path: name, Kind: findingDeniedLicense, Message: fmt.Sprintf("is licensed under %s which is denied by the policy", id)})
+		case verdictUnknown:
+			violation = true
+			r.Report(finding{Path: name, Kind: findingUnknownLicense, Message: fmt.Sprintf("is licensed under %s which is not covered by the policy", id)})
+		case verdictReview:
+			r.Report(finding{Path: name, Kind: findingReviewLicense, Message: fmt.Sprintf("is licensed under %s which requires a review", id)})
+		}
+	}
+
+	if err := r.Flush(); err != nil {
+		return err
+	}
+
+	if unavailable {
+		return &Error{code: errModuleSourcesUnavailable}
+	}
+	if violation {
+		return &Error{code: exitDependenciesViolatePolicy}
+	}
+	return nil
+}
+
+// readPolicy reads and validates the policy file found in path.
+func readPolicy(path string) (policy, error) {
+	var p policy
+	f, err := os.Open(path)
+	if err != nil {
+		return p, err
+	}
+	defer f.Close()
+
+	var dec = json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return p, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return p, p.validate()
+}
+
+func (p policy) validate() error {
+	var seen = make(map[string]string)
+	var lists = []struct {
+		name string
+		ids  []string
+	}{{"allow", p.Allow}, {"deny", p.Deny}, {"review", p.Review}}
+	for _, list := range lists {
+		for _, id := range list.ids {
+			if other, ok := seen[id]; ok {
+				return fmt.Errorf("license %s is listed in both %s and %s", id, other, list.name)
+			}
+			seen[id] = list.name
+		}
+	}
+
+	for _, e := range p.Exceptions {
+		if e.Module == "" {
+			return errors.New("policy exceptions must specify a module")
+		}
+		if strings.TrimSpace(e.Justification) == "" {
+			return fmt.Errorf("policy exception for %s must have a justification", e.Module)
+		}
+	}
+
+	return nil
+}
+
+// classify returns the verdict of the policy for a module licensed under the
+// SPDX identifier id.
+func (p policy) classify(m module, id string) string {
+	for _, e := range p.Exceptions {
+		if e.Module == m.Path && (e.Version == "" || e.Version == m.Version) {
+			return verdictException
+		}
+	}
+
+	switch {
+	case id == licensing.Unknown:
+		return verdictUnknown
+	case stringInSlice(id, p.Deny):
+		return verdictDenied
+	case stringInSlice(id, p.Review):
+		return verdictReview
+	case stringInSlice(id, p.Allow):
+		return verdictAllowed
+	}
+
+	return verdictUnknown
+}
@@ -0,0 +1,152 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_deps(t *testing.T) {
+	defer stubModules(t)()
+
+	tests := []struct {
+		name       string
+		policy     string
+		format     string
+		want       int
+		wantOutput string
+	}{
+		{
+			name:   "Denied and unknown licenses fail",
+			policy: `{"allow": ["MIT"], "deny": ["MPL-2.0"]}`,
+			want:   exitDependenciesViolatePolicy,
+			wantOutput: `
+github.com/mpl/dep@v0.1.0: is licensed under MPL-2.0 which is denied by the policy
+github.com/missing/dep@v2.0.0: is licensed under UNKNOWN which is not covered by the policy
+`[1:],
+		},
+		{
+			name: "Exceptions and review licenses pass",
+			policy: `{
+	"allow": ["MIT"],
+	"review": ["MPL-2.0"],
+	"exceptions": [{"module": "github.com/missing/dep", "justification": "dual licensed, see #42"}]
+}`,
+			want: 0,
+			wantOutput: `
+github.com/mpl/dep@v0.1.0: is licensed under MPL-2.0 which requires a review
+`[1:],
+		},
+		{
+			name: "Exceptions for another version don't apply",
+			policy: `{
+	"allow": ["MIT", "MPL-2.0"],
+	"exceptions": [{"module": "github.com/missing/dep", "version": "v1.0.0", "justification": "approved"}]
+}`,
+			format: formatJSON,
+			want:   exitDependenciesViolatePolicy,
+			wantOutput: `
+{
+  "findings": [
+    {
+      "path": "github.com/missing/dep@v2.0.0",
+      "kind": "unknown-license",
+      "message": "is licensed under UNKNOWN which is not covered by the policy"
+    }
+  ]
+}
+`[1:],
+		},
+		{
+			name:   "License listed twice is invalid",
+			policy: `{"allow": ["MIT"], "deny": ["MIT"]}`,
+			want:   errInvalidPolicy,
+		},
+		{
+			name:   "Exception without justification is invalid",
+			policy: `{"exceptions": [{"module": "github.com/missing/dep"}]}`,
+			want:   errInvalidPolicy,
+		},
+		{
+			name:   "Unknown fields are invalid",
+			policy: `{"allowed": ["MIT"]}`,
+			want:   errInvalidPolicy,
+		},
+		{
+			name:   "Unknown format fails",
+			policy: `{}`,
+			format: "xml",
+			want:   errUnknownFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dir = t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, defaultPolicyFile), []byte(tt.policy), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf = new(bytes.Buffer)
+			var err = deps(depsOptions{path: dir, format: tt.format}, buf)
+			if got := Code(err); got != tt.want {
+				t.Errorf("deps() = %v, want %v (%v)", got, tt.want, err)
+			}
+
+			if got := buf.String(); got != tt.wantOutput {
+				t.Errorf("Output = \n%v\n want \n%v", got, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func Test_deps_missingPolicy(t *testing.T) {
+	if got := Code(deps(depsOptions{path: t.TempDir()}, new(bytes.Buffer))); got != errInvalidPolicy {
+		t.Errorf("deps() = %v, want %v", got, errInvalidPolicy)
+	}
+}
+
+func Test_deps_unavailableSources(t *testing.T) {
+	var orig = listModules
+	defer func() { listModules = orig }()
+	listModules = func(string) ([]module, error) {
+		return []module{
+			{Path: "github.com/elastic/example", Main: true},
+			{Path: "github.com/absent/dep", Version: "v1.0.0"},
+			{Path: "github.com/excepted/dep", Version: "v1.0.0"},
+		}, nil
+	}
+
+	var dir = t.TempDir()
+	var policy = `{"allow": ["MIT"], "exceptions": [{"module": "github.com/excepted/dep", "justification": "approved"}]}`
+	if err := os.WriteFile(filepath.Join(dir, defaultPolicyFile), []byte(policy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf = new(bytes.Buffer)
+	if got := Code(deps(depsOptions{path: dir}, buf)); got != errModuleSourcesUnavailable {
+		t.Errorf("deps() = %v, want %v", got, errModuleSourcesUnavailable)
+	}
+
+	var want = "github.com/absent/dep@v1.0.0: module sources not available, run go mod download\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+// classifyBytes is the number of bytes at the top of a file which are
+// classified to find the license of a go-licenser:license directive.
+const classifyBytes = 8 << 10
+
+// readDirectives parses the directives of the file found in path.
+func readDirectives(path string) (licensing.Directives, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return licensing.Directives{}, err
+	}
+	defer f.Close()
+
+	return licensing.ParseDirectives(f), nil
+}
+
+// checkDirectives applies the directives of the file in path, whose preamble
+// holds them. It returns true when the directives settle the outcome for
+// the file, otherwise the file must be checked against the header of the
+// d.License key of the registry.
+func checkDirectives(path string, d licensing.Directives, preamble []byte, opts options, r reporter) (bool, error) {
+	if len(d.Problems) > 0 {
+		for _, p := range d.Problems {
+			reportFile(r, path, findingInvalidDirective, p)
+		}
+		return true, &Error{code: exitInvalidDirective}
+	}
+
+	if d.Ignore {
+		if licensing.ContainsHeader(bytes.NewReader(preamble), headerLines(preamble, opts.license, opts)) {
+			reportFile(r, path, findingStaleDirective, "has a go-licenser:ignore directive but contains the license header")
+			return true, &Error{code: exitInvalidDirective}
+		}
+		reportOptOut(r, path, "is ignored by a go-licenser:ignore directive", d, opts)
+		return true, nil
+	}
+
+	if d.License == opts.license {
+		reportFile(r, path, findingStaleDirective, fmt.Sprintf("has a go-licenser:license=%s directive for the default license", d.License))
+		return true, &Error{code: exitInvalidDirective}
+	}
+
+	var optOut = fmt.Sprintf("is licensed under %s by a go-licenser:license directive", d.License)
+	if _, ok := opts.licenses().Lookup(d.License); ok {
+		reportOptOut(r, path, optOut, d, opts)
+		return false, nil
+	}
+
+	if got, ok := contradictedLicense(preamble, d.License); ok {
+		reportFile(r, path, findingStaleDirective, fmt.Sprintf("has a go-licenser:license=%s directive but its header is %s", d.License, got))
+		return true, &Error{code: exitInvalidDirective}
+	}
+	reportOptOut(r, path, optOut, d, opts)
+	return true, nil
+}
+
+// contradictedLicense returns the license found in the preamble when it isn't
+// the license of a go-licenser:license directive, whose header the registry
+// doesn't have. The notices of such licenses are often too short to be
+// classified, like the three lines pointing to the BSD license of the Go
+// sources, so the directive is trusted unless another license is found.
+func contradictedLicense(preamble []byte, license string) (string, bool) {
+	var head = preamble
+	if len(head) > classifyBytes {
+		head = head[:classifyBytes]
+	}
+
+	var got = licensing.Classify(head)
+	return got, got != licensing.Unknown && got != license
+}
+
+func reportOptOut(r reporter, path, message string, d licensing.Directives, opts options) {
+	if !opts.optOuts {
+		return
+	}
+	if d.Reason != "" {
+		message += ": " + d.Reason
+	}
+	reportFile(r, path, findingOptOut, message)
+}
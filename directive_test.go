@@ -0,0 +1,140 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// testGoBSDHeader is the short notice of the Go sources, which refers to the
+// license rather than holding it.
+const testGoBSDHeader = `// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+`
+
+const testMITHeader = `// Copyright (c) 2015 Someone Else
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction.
+`
+
+func Test_run_directives(t *testing.T) {
+	var elastic, err = renderHeader(options{license: "Elastic", licensor: defaultLicensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apache []byte
+	if apache, err = renderHeader(options{license: defaultLicense, licensor: defaultLicensor}); err != nil {
+		t.Fatal(err)
+	}
+
+	var files = map[string]string{
+		"ignored.src":  "// go-licenser:ignore vendored code\n",
+		"mit.src":      testMITHeader + "\n// go-licenser:license=MIT\n",
+		"mitmiss.src":  "// go-licenser:license=MIT\n",
+		"elastic.src":  string(elastic) + "\n// go-licenser:license=Elastic\n",
+		"elmiss.src":   "// go-licenser:license=Elastic\n",
+		"stale.src":    string(apache) + "\n// go-licenser:ignore\n",
+		"default.src":  "// go-licenser:license=ASL2\n",
+		"bsd.src":      testMITHeader + "\n// go-licenser:license=BSD-3-Clause\n",
+		"gobsd.src":    testGoBSDHeader + "\n// go-licenser:license=BSD-3-Clause\n",
+		"invalid.src":  "// go-licenser:licence=MIT\n",
+		"noheader.src": "package main\n",
+	}
+
+	tests := []struct {
+		name       string
+		dry        bool
+		optOuts    bool
+		want       int
+		wantOutput string
+		wantFixed  map[string]string
+	}{
+		{
+			name:    "Dry run reports the directives outcome",
+			dry:     true,
+			optOuts: true,
+			want:    exitInvalidDirective,
+			wantOutput: `
+bsd.src: has a go-licenser:license=BSD-3-Clause directive but its header is MIT
+default.src: has a go-licenser:license=ASL2 directive for the default license
+elastic.src: is licensed under Elastic by a go-licenser:license directive
+elmiss.src: is licensed under Elastic by a go-licenser:license directive
+elmiss.src: is missing the license header
+gobsd.src: is licensed under BSD-3-Clause by a go-licenser:license directive
+ignored.src: is ignored by a go-licenser:ignore directive: vendored code
+invalid.src: line 1: unknown directive go-licenser:licence=MIT
+mit.src: is licensed under MIT by a go-licenser:license directive
+mitmiss.src: is licensed under MIT by a go-licenser:license directive
+noheader.src: is missing the license header
+stale.src: has a go-licenser:ignore directive but contains the license header
+`[1:],
+		},
+		{
+			name: "Fix adds the header of the directive license",
+			want: exitInvalidDirective,
+			wantOutput: `
+bsd.src: has a go-licenser:license=BSD-3-Clause directive but its header is MIT
+default.src: has a go-licenser:license=ASL2 directive for the default license
+invalid.src: line 1: unknown directive go-licenser:licence=MIT
+stale.src: has a go-licenser:ignore directive but contains the license header
+`[1:],
+			wantFixed: map[string]string{
+				"elmiss.src":   string(elastic) + "\n// go-licenser:license=Elastic\n",
+				"noheader.src": string(apache) + "\npackage main\n",
+				"ignored.src":  files["ignored.src"],
+				"mit.src":      files["mit.src"],
+				"gobsd.src":    files["gobsd.src"],
+				"mitmiss.src":  files["mitmiss.src"],
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fsys = fixturesFS(t, files)
+
+			var buf = new(bytes.Buffer)
+			var err = runFS(context.Background(), fsys, options{
+				license:  defaultLicense,
+				licensor: defaultLicensor,
+				ext:      ".src",
+				dry:      tt.dry,
+				optOuts:  tt.optOuts,
+			}, buf)
+			if got := Code(err); got != tt.want {
+				t.Errorf("run() = %v, want %v", got, tt.want)
+			}
+
+			var wantOutput = filepath.FromSlash(tt.wantOutput)
+			if got := buf.String(); got != wantOutput {
+				t.Errorf("Output = \n%v\n want \n%v", got, wantOutput)
+			}
+
+			for name, want := range tt.wantFixed {
+				if got := fsys.MapFS[name].Data; string(got) != want {
+					t.Errorf("%s = \n%s\n want \n%s", name, got, want)
+				}
+			}
+		})
+	}
+}
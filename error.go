@@ -17,6 +17,57 @@
 
 package main
 
+import (
+	"errors"
+	"strings"
+)
+
+// Error categories, which classify the errors by their exit code.
+const (
+	categoryStat           = "stat"
+	categoryOpen           = "open"
+	categoryRewrite        = "rewrite"
+	categoryEncoding       = "encoding"
+	categoryUnknownLicense = "unknown license"
+	categoryCancelled      = "cancelled"
+	categoryFinding        = "finding"
+	categoryOther          = "other"
+)
+
+// precedence orders the exit codes from the most to the least important. The
+// exit code of accumulated errors is the one of the error which comes first,
+// the codes which aren't listed come last.
+var precedence = []int{
+	exitInterrupted,
+	exitTimedOut,
+	errUnknownLicense,
+	errUnknownFormat,
+	errUnknownForeignCopyright,
+	errInvalidVariants,
+	errInvalidPolicy,
+	exitFailedToStatTree,
+	errFailedReadingArchive,
+	errFailedReadingRevision,
+	errFailedReadingIndex,
+	errFailedToListModules,
+	errModuleSourcesUnavailable,
+	exitFailedToWalkPath,
+	exitFailedToStatFile,
+	exitFailedToOpenWalkFile,
+	errFailedRewrittingFile,
+	errUnsupportedEncoding,
+	errFailedWritingCache,
+	errFailedGeneratingNotice,
+	errFailedInstallingHook,
+	errUnexpected,
+	exitForeignCopyright,
+	exitInvalidDirective,
+	exitLicenseFileMismatch,
+	exitDependenciesViolatePolicy,
+	exitNoticeNeedsToBeRewritten,
+	exitSourceNeedsToBeRewritten,
+}
+
 // Error wraps a normal error with an Exitcode.
 type Error struct {
 	err  error
@@ -30,10 +81,148 @@ func (e Error) Error() string {
 	return "<nil>"
 }
 
-// Code returns the exitcode for the error
+// Unwrap returns the wrapped error.
+func (e Error) Unwrap() error { return e.err }
+
+// Is matches the targets of type *Error without a wrapped error and with the
+// same code, so that errors.Is(err, &Error{code: errFailedRewrittingFile})
+// reports whether err is or contains a failure to rewrite a file.
+func (e Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.err == nil && t.code == e.code
+}
+
+// Category returns the category of the error.
+func (e Error) Category() string {
+	switch e.code {
+	case exitFailedToStatTree, exitFailedToStatFile:
+		return categoryStat
+	case exitFailedToWalkPath, exitFailedToOpenWalkFile, errFailedReadingArchive, errFailedReadingRevision:
+		return categoryOpen
+	case errFailedRewrittingFile:
+		return categoryRewrite
+	case errUnsupportedEncoding:
+		return categoryEncoding
+	case errUnknownLicense:
+		return categoryUnknownLicense
+	case exitInterrupted, exitTimedOut:
+		return categoryCancelled
+	}
+	if e.err == nil {
+		return categoryFinding
+	}
+	return categoryOther
+}
+
+// Errors accumulates the errors of a run. The findings, which are errors
+// without a wrapped error, only contribute their exit code, while the
+// failures are all kept.
+type Errors struct {
+	errs []error
+}
+
+// Add accumulates err, flattening the accumulated errors it may hold. It
+// returns true when err isn't nil.
+func (e *Errors) Add(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var errs *Errors
+	if errors.As(err, &errs) {
+		e.errs = append(e.errs, errs.errs...)
+		return len(errs.errs) > 0
+	}
+
+	e.errs = append(e.errs, err)
+	return true
+}
+
+// Err returns nil when no errors were accumulated, e itself when any of them
+// is a failure, and otherwise the finding with the highest precedence.
+func (e *Errors) Err() error {
+	if len(e.errs) == 0 {
+		return nil
+	}
+	if len(e.failures()) > 0 {
+		return e
+	}
+
+	var first error
+	for _, err := range e.errs {
+		if first == nil || rank(Code(err)) < rank(Code(first)) {
+			first = err
+		}
+	}
+	return first
+}
+
+// failures returns the accumulated errors which aren't findings.
+func (e *Errors) failures() []error {
+	var failures []error
+	for _, err := range e.errs {
+		if err.Error() != "<nil>" {
+			failures = append(failures, err)
+		}
+	}
+	return failures
+}
+
+// Error returns one line per failure, prefixed by its category.
+func (e *Errors) Error() string {
+	var lines []string
+	for _, err := range e.failures() {
+		var category = categoryOther
+		var ee *Error
+		if errors.As(err, &ee) {
+			category = ee.Category()
+		}
+		lines = append(lines, category+": "+err.Error())
+	}
+	if len(lines) == 0 {
+		return "<nil>"
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Unwrap returns the accumulated errors.
+func (e *Errors) Unwrap() []error { return e.errs }
+
+// code returns the exit code with the highest precedence.
+func (e *Errors) code() int {
+	var code int
+	for i, err := range e.errs {
+		if c := Code(err); i == 0 || rank(c) < rank(code) {
+			code = c
+		}
+	}
+	return code
+}
+
+func rank(code int) int {
+	for i, c := range precedence {
+		if c == code {
+			return i
+		}
+	}
+	return len(precedence)
+}
+
+// Code returns the exitcode for the error. The errors which don't carry an
+// exit code, like a failure to write the report, are unexpected failures.
 func Code(e error) int {
-	if err, ok := e.(*Error); ok {
+	if e == nil {
+		return exitDefault
+	}
+
+	var errs *Errors
+	if errors.As(e, &errs) {
+		return errs.code()
+	}
+
+	var err *Error
+	if errors.As(e, &err) {
 		return err.code
 	}
-	return 0
+	return errUnexpected
 }
@@ -18,7 +18,12 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -37,11 +42,23 @@ func TestCode(t *testing.T) {
 			want: 0,
 		},
 		{
-			name: "standard error returns 0",
+			name: "standard error returns errUnexpected",
 			args: args{
 				e: errors.New("an error"),
 			},
-			want: 0,
+			want: errUnexpected,
+		},
+		{
+			name: "accumulated standard errors return errUnexpected",
+			args: args{
+				e: func() error {
+					var errs Errors
+					errs.Add(errors.New("an error"))
+					errs.Add(&Error{code: exitSourceNeedsToBeRewritten})
+					return errs.Err()
+				}(),
+			},
+			want: errUnexpected,
 		},
 		{
 			name: "Error error returns 1",
@@ -111,3 +128,154 @@ func TestError_Error(t *testing.T) {
 		})
 	}
 }
+
+func Test_precedence(t *testing.T) {
+	// Every exit code but the default one must be ranked.
+	for code := exitSourceNeedsToBeRewritten; code <= errUnexpected; code++ {
+		if rank(code) == len(precedence) {
+			t.Errorf("exit code %d has no precedence", code)
+		}
+	}
+	if len(precedence) != errUnexpected {
+		t.Errorf("precedence has %d exit codes, want %d", len(precedence), errUnexpected)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var errOpen = &Error{err: errors.New("open a.go: no such file or directory"), code: exitFailedToOpenWalkFile}
+	var errRewrite = &Error{err: errors.New("open b.go: permission denied"), code: errFailedRewrittingFile}
+	var missing = &Error{code: exitSourceNeedsToBeRewritten}
+	var invalid = &Error{code: exitInvalidDirective}
+
+	tests := []struct {
+		name     string
+		errs     []error
+		wantErr  error
+		wantCode int
+		wantText string
+	}{
+		{
+			name: "No errors",
+			errs: []error{nil, nil},
+		},
+		{
+			name:     "Findings return the one with the highest precedence",
+			errs:     []error{missing, invalid, missing},
+			wantErr:  invalid,
+			wantCode: exitInvalidDirective,
+			wantText: "<nil>",
+		},
+		{
+			name:     "Failures are all kept",
+			errs:     []error{missing, errRewrite, nil, errOpen},
+			wantCode: exitFailedToOpenWalkFile,
+			wantText: "rewrite: open b.go: permission denied\nopen: open a.go: no such file or directory\n",
+		},
+		{
+			name: "Accumulated errors are flattened",
+			errs: []error{errRewrite, func() error {
+				var nested Errors
+				nested.Add(errOpen)
+				return nested.Err()
+			}()},
+			wantCode: exitFailedToOpenWalkFile,
+			wantText: "rewrite: open b.go: permission denied\nopen: open a.go: no such file or directory\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs Errors
+			for _, err := range tt.errs {
+				errs.Add(err)
+			}
+
+			var err = errs.Err()
+			if tt.wantErr != nil && err != tt.wantErr {
+				t.Errorf("Err() = %#v, want %#v", err, tt.wantErr)
+			}
+			if got := Code(err); got != tt.wantCode {
+				t.Errorf("Code() = %v, want %v", got, tt.wantCode)
+			}
+			if err == nil {
+				if tt.wantText != "" {
+					t.Errorf("Err() = nil, want %q", tt.wantText)
+				}
+				return
+			}
+			if got := err.Error(); got != tt.wantText {
+				t.Errorf("Error() = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestErrors_Is(t *testing.T) {
+	var pathErr = &os.PathError{Op: "open", Path: "a.go", Err: fs.ErrNotExist}
+
+	var errs Errors
+	errs.Add(&Error{code: exitSourceNeedsToBeRewritten})
+	errs.Add(&Error{err: pathErr, code: exitFailedToOpenWalkFile})
+	var err = errs.Err()
+
+	if !errors.Is(err, &Error{code: exitFailedToOpenWalkFile}) {
+		t.Error("errors.Is() = false for the open failure")
+	}
+	if !errors.Is(err, &Error{code: exitSourceNeedsToBeRewritten}) {
+		t.Error("errors.Is() = false for the finding")
+	}
+	if errors.Is(err, &Error{code: errFailedRewrittingFile}) {
+		t.Error("errors.Is() = true for a rewrite failure")
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("errors.Is() = false for the wrapped error")
+	}
+
+	var got *os.PathError
+	if !errors.As(err, &got) || got != pathErr {
+		t.Errorf("errors.As() = %v, want %v", got, pathErr)
+	}
+}
+
+func TestError_Category(t *testing.T) {
+	for code, want := range map[int]string{
+		exitFailedToStatFile:     categoryStat,
+		exitFailedToOpenWalkFile: categoryOpen,
+		errFailedRewrittingFile:  categoryRewrite,
+		errUnknownLicense:        categoryUnknownLicense,
+		errFailedWritingCache:    categoryOther,
+	} {
+		if got := (Error{err: errors.New("an error"), code: code}).Category(); got != want {
+			t.Errorf("Category() of %d = %v, want %v", code, got, want)
+		}
+	}
+	if got := (Error{code: exitSourceNeedsToBeRewritten}).Category(); got != categoryFinding {
+		t.Errorf("Category() of a finding = %v, want %v", got, categoryFinding)
+	}
+}
+
+func Test_run_failFast(t *testing.T) {
+	var dir = t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		if err := os.Symlink(filepath.Join(dir, "missing", name), filepath.Join(dir, name)); err != nil {
+			t.Skip("symlinks aren't supported:", err)
+		}
+	}
+
+	for failFast, want := range map[bool]int{false: 3, true: 1} {
+		var err = run(context.Background(), []string{dir}, options{
+			license:  defaultLicense,
+			licensor: defaultLicensor,
+			ext:      defaultExt,
+			dry:      true,
+			failFast: failFast,
+		}, new(bytes.Buffer))
+
+		if got := Code(err); got != exitFailedToOpenWalkFile {
+			t.Errorf("run(failFast=%v) = %v, want %v", failFast, got, exitFailedToOpenWalkFile)
+		}
+		var errs *Errors
+		if !errors.As(err, &errs) || len(errs.failures()) != want {
+			t.Errorf("run(failFast=%v) = %v, want %d failures", failFast, err, want)
+		}
+	}
+}
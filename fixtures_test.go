@@ -0,0 +1,90 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// runFS checks all the files of fsys, like the in-memory tree of fixturesFS.
+// Unless in dry mode, fsys must be a licensing.WriteFS so that the files can
+// be fixed.
+func runFS(ctx context.Context, fsys fs.FS, opts options, out io.Writer) error {
+	return runTree(ctx, fsTree(fsys), opts, out)
+}
+
+// memFS is an in-memory tree whose files can be rewritten.
+type memFS struct {
+	fstest.MapFS
+}
+
+func (m memFS) WriteFile(name string, perm fs.FileMode, write func(io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+
+	m.MapFS[name] = &fstest.MapFile{Data: buf.Bytes(), Mode: perm}
+	return nil
+}
+
+// fixturesFS returns the fixtures as an in-memory tree, along with the files
+// which are added or replaced in it.
+func fixturesFS(t *testing.T, files map[string]string) memFS {
+	var m = memFS{MapFS: make(fstest.MapFS)}
+	if err := fs.WalkDir(os.DirFS(fixtures), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(os.DirFS(fixtures), name)
+		if err != nil {
+			return err
+		}
+		m.MapFS[strings.Replace(name, ".testdata", ".go", 1)] = &fstest.MapFile{Data: data, Mode: 0644}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, text := range files {
+		m.MapFS[name] = &fstest.MapFile{Data: []byte(text), Mode: 0644}
+	}
+	return m
+}
+
+// writeFiles writes the files, by their slash separated name, to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		var path = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+// Modes for the headers containing the copyright notice of someone other
+// than the licensor.
+const (
+	foreignReplace  = "replace"
+	foreignPreserve = "preserve"
+	foreignRefuse   = "refuse"
+)
+
+var foreignModes = []string{foreignReplace, foreignPreserve, foreignRefuse}
+
+// foreignHolders returns the copyright holders found in the header of src
+// which aren't the licensor.
+func foreignHolders(src []byte, licensor string) []string {
+	var own = strings.ToLower(strings.TrimRight(licensor, " .,"))
+
+	var holders []string
+	for _, h := range licensing.CopyrightHolders(src) {
+		if own == "" || !strings.Contains(strings.ToLower(h), own) {
+			holders = append(holders, h)
+		}
+	}
+	return holders
+}
+
+// rewriteHeader returns src with the header added. When the existing header
+// holds a third-party copyright notice, it's either replaced, preserved below
+// the header or the file is reported and left alone, according to the
+// foreign copyright mode.
+func rewriteHeader(path string, src, headerBytes []byte, opts options, r reporter) ([]byte, error) {
+	var header = append([]byte(nil), headerBytes...)
+
+	var holders []string
+	if opts.foreignCopyright != "" && opts.foreignCopyright != foreignReplace {
+		holders = foreignHolders(src, opts.licensor)
+	}
+	if len(holders) == 0 {
+		return licensing.RewriteSourceWithHeader(path, src, header), nil
+	}
+
+	if opts.foreignCopyright == foreignRefuse {
+		reportFile(r, path, findingForeignCopyright, fmt.Sprintf(
+			"is missing the license header and has a copyright notice of %s, refusing to replace it",
+			strings.Join(holders, ", "),
+		))
+		return nil, &Error{code: exitForeignCopyright}
+	}
+
+	return licensing.PrependHeader(src, header), nil
+}
+
+// rewriteFile adds the header to the file name of the tree, taking the
+// third-party copyright notices into account. In dry mode the file is only
+// reported.
+func rewriteFile(t tree, name string, headerBytes []byte, opts options, r reporter) error {
+	var path = t.path(name)
+	preamble, err := readPreamble(t, name)
+	if err != nil {
+		return err
+	}
+
+	fixed, err := rewriteHeader(path, preamble, headerBytes, opts, r)
+	if err != nil {
+		return err
+	}
+
+	if opts.dry {
+		reportFile(r, path, findingMissingHeader, "is missing the license header")
+		return &Error{code: exitSourceNeedsToBeRewritten}
+	}
+
+	return rewritePreamble(t, name, preamble, fixed)
+}
@@ -0,0 +1,154 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	testForeignSrc = `
+// Copyright 2012 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+`
+	testOwnSrc = `
+// Copyright 2017 Elasticsearch B.V. All rights reserved.
+
+package main
+`
+)
+
+func Test_foreignHolders(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		licensor string
+		want     []string
+	}{
+		{name: "No header", src: "package main\n", licensor: defaultLicensor},
+		{name: "Own copyright", src: testOwnSrc[1:], licensor: defaultLicensor},
+		{name: "Own copyright is matched without case", src: testOwnSrc[1:], licensor: "elasticsearch b.v"},
+		{name: "Third party copyright", src: testForeignSrc[1:], licensor: defaultLicensor, want: []string{"The Go Authors"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := foreignHolders([]byte(tt.src), tt.licensor); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("foreignHolders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_run_foreignCopyright(t *testing.T) {
+	header, err := renderHeader(options{license: defaultLicense, licensor: defaultLicensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		mode       string
+		dry        bool
+		want       int
+		wantOutput string
+		wantFiles  map[string]string
+	}{
+		{
+			name: "Replace overwrites the third party notice",
+			mode: foreignReplace,
+			wantFiles: map[string]string{
+				"foreign.src": string(header) + "\npackage main\n",
+				"own.src":     string(header) + "\npackage main\n",
+			},
+		},
+		{
+			name: "Preserve keeps the third party notice below the header",
+			mode: foreignPreserve,
+			wantFiles: map[string]string{
+				"foreign.src": string(header) + "\n" + testForeignSrc[1:],
+				"own.src":     string(header) + "\npackage main\n",
+			},
+		},
+		{
+			name: "Preserve dry run reports the missing header",
+			mode: foreignPreserve,
+			dry:  true,
+			want: exitSourceNeedsToBeRewritten,
+			wantOutput: `
+foreign.src: is missing the license header
+own.src: is missing the license header
+`[1:],
+		},
+		{
+			name: "Refuse leaves the third party notice alone",
+			mode: foreignRefuse,
+			want: exitForeignCopyright,
+			wantOutput: `
+foreign.src: is missing the license header and has a copyright notice of The Go Authors, refusing to replace it
+`[1:],
+			wantFiles: map[string]string{
+				"foreign.src": testForeignSrc[1:],
+				"own.src":     string(header) + "\npackage main\n",
+			},
+		},
+		{
+			name: "Unknown mode",
+			mode: "keep",
+			want: errUnknownForeignCopyright,
+			wantFiles: map[string]string{
+				"foreign.src": testForeignSrc[1:],
+				"own.src":     testOwnSrc[1:],
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fsys = fixturesFS(t, map[string]string{"foreign.src": testForeignSrc[1:], "own.src": testOwnSrc[1:]})
+
+			var buf = new(bytes.Buffer)
+			var err = runFS(context.Background(), fsys, options{
+				license:          defaultLicense,
+				licensor:         defaultLicensor,
+				ext:              ".src",
+				dry:              tt.dry,
+				foreignCopyright: tt.mode,
+			}, buf)
+			if got := Code(err); got != tt.want {
+				t.Errorf("run() = %v, want %v", got, tt.want)
+			}
+
+			var wantOutput = filepath.FromSlash(tt.wantOutput)
+			if got := buf.String(); got != wantOutput {
+				t.Errorf("Output = \n%v\n want \n%v", got, wantOutput)
+			}
+
+			for name, want := range tt.wantFiles {
+				if got := fsys.MapFS[name].Data; string(got) != want {
+					t.Errorf("%s = \n%s\n want \n%s", name, got, want)
+				}
+			}
+		})
+	}
+}
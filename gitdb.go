@@ -0,0 +1,526 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxDeltaDepth bounds the chains of deltas of the packed objects, which
+	// git limits to 50 by default.
+	maxDeltaDepth = 10000
+
+	// maxObjectSize bounds the size of the objects, so that a corrupt size
+	// fails instead of allocating it.
+	maxObjectSize = 1 << 34
+
+	// deltaCacheSize is the number of delta bases which are kept in memory.
+	deltaCacheSize = 256
+)
+
+// gitHash is the SHA-1 name of a git object.
+type gitHash [20]byte
+
+func (h gitHash) String() string { return hex.EncodeToString(h[:]) }
+
+func parseGitHash(s string) (gitHash, bool) {
+	var h gitHash
+	if len(s) != hex.EncodedLen(len(h)) {
+		return h, false
+	}
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, false
+	}
+	return h, true
+}
+
+// gitDB reads the objects of a git repository straight from its object
+// database, the loose objects and the packfiles, without a checkout. Only
+// the repositories using SHA-1 object names are supported.
+type gitDB struct {
+	// gitDir holds HEAD, commonDir the refs and the objects which are
+	// shared by all the worktrees of the repository.
+	gitDir    string
+	commonDir string
+
+	objects []string
+	packs   []*gitPack
+}
+
+// openGitDB opens the repository whose git directory is gitDir. As within
+// git hooks, the GIT_OBJECT_DIRECTORY and GIT_ALTERNATE_OBJECT_DIRECTORIES
+// environment variables override the object directories, which is where the
+// objects of a push are found before it's accepted.
+func openGitDB(gitDir string) (*gitDB, error) {
+	var db = gitDB{gitDir: gitDir, commonDir: gitDir}
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		db.commonDir = strings.TrimSpace(string(b))
+		if !filepath.IsAbs(db.commonDir) {
+			db.commonDir = filepath.Join(gitDir, db.commonDir)
+		}
+	}
+
+	if format := gitConfig(db.commonDir, "extensions", "objectformat"); format != "" && format != "sha1" {
+		return nil, fmt.Errorf("%s: unsupported object format %s", gitDir, format)
+	}
+
+	var objects = filepath.Join(db.commonDir, "objects")
+	if dir := os.Getenv("GIT_OBJECT_DIRECTORY"); dir != "" {
+		objects = dir
+	}
+	var dirs = append([]string{objects}, filepath.SplitList(os.Getenv("GIT_ALTERNATE_OBJECT_DIRECTORIES"))...)
+	for _, dir := range dirs {
+		if err := db.addObjects(dir, 0); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &db, nil
+}
+
+// addObjects adds the object directory dir, along with its alternates.
+func (db *gitDB) addObjects(dir string, depth int) error {
+	if dir == "" || depth > 5 || stringInSlice(dir, db.objects) {
+		return nil
+	}
+	db.objects = append(db.objects, dir)
+
+	indexes, err := filepath.Glob(filepath.Join(dir, "pack", "pack-*.idx"))
+	if err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		p, err := openGitPack(idx)
+		if err != nil {
+			return err
+		}
+		db.packs = append(db.packs, p)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "info", "alternates"))
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line == "" || line[0] == '#' {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(dir, line)
+		}
+		if err := db.addObjects(line, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the packfiles of the repository.
+func (db *gitDB) Close() error {
+	var errs []error
+	for _, p := range db.packs {
+		errs = append(errs, p.f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// object returns the type and the contents of the object h.
+func (db *gitDB) object(h gitHash) (string, []byte, error) {
+	return db.read(h, 0)
+}
+
+func (db *gitDB) read(h gitHash, depth int) (string, []byte, error) {
+	for _, p := range db.packs {
+		if offset, ok := p.find(h); ok {
+			return p.object(db, offset, depth)
+		}
+	}
+
+	var name = h.String()
+	for _, dir := range db.objects {
+		typ, data, err := readLooseObject(filepath.Join(dir, name[:2], name[2:]))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return typ, data, err
+	}
+	return "", nil, fmt.Errorf("object %s: %w", h, fs.ErrNotExist)
+}
+
+// abbrev returns the object whose name starts with the hexadecimal prefix.
+func (db *gitDB) abbrev(prefix string) (gitHash, error) {
+	var found = make(map[gitHash]bool)
+	for _, p := range db.packs {
+		for _, h := range p.abbrev(prefix) {
+			found[h] = true
+		}
+	}
+	for _, dir := range db.objects {
+		entries, _ := os.ReadDir(filepath.Join(dir, prefix[:2]))
+		for _, e := range entries {
+			if h, ok := parseGitHash(prefix[:2] + e.Name()); ok && strings.HasPrefix(e.Name(), prefix[2:]) {
+				found[h] = true
+			}
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return gitHash{}, fmt.Errorf("object %s: %w", prefix, fs.ErrNotExist)
+	case 1:
+		for h := range found {
+			return h, nil
+		}
+	}
+	return gitHash{}, fmt.Errorf("object name %s is ambiguous", prefix)
+}
+
+// readLooseObject reads the zlib compressed object found in path.
+func readLooseObject(path string) (string, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer zr.Close()
+
+	var r = bufio.NewReader(zr)
+	hdr, err := r.ReadString(0)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: invalid object header: %w", path, err)
+	}
+	typ, size, _ := strings.Cut(strings.TrimSuffix(hdr, "\x00"), " ")
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || n < 0 || n > maxObjectSize {
+		return "", nil, fmt.Errorf("%s: invalid object size %q", path, size)
+	}
+
+	var data = make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return typ, data, nil
+}
+
+// Types of the objects, as encoded in the packfiles.
+var packTypes = map[byte]string{1: "commit", 2: "tree", 3: "blob", 4: "tag"}
+
+const (
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+// gitPack is a packfile along with its version 2 index, which is held in
+// memory.
+type gitPack struct {
+	f      *os.File
+	fanout [256]uint32
+	// hashes, offsets and large hold the sorted object names, the offsets
+	// of the objects in the pack and the offsets which don't fit 31 bits.
+	hashes  []byte
+	offsets []byte
+	large   []byte
+
+	bases map[int64]packedObject
+}
+
+type packedObject struct {
+	typ  string
+	data []byte
+}
+
+func openGitPack(idxPath string) (*gitPack, error) {
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < 8+256*4 || string(idx[:4]) != "\xfftOc" || binary.BigEndian.Uint32(idx[4:]) != 2 {
+		return nil, fmt.Errorf("%s: unsupported pack index", idxPath)
+	}
+
+	var p = gitPack{bases: make(map[int64]packedObject)}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[8+4*i:])
+	}
+	var n, pos = int(p.fanout[255]), 8 + 256*4
+	if len(idx) < pos+n*(20+4+4)+2*20 {
+		return nil, fmt.Errorf("%s: truncated pack index", idxPath)
+	}
+	p.hashes, pos = idx[pos:pos+20*n], pos+20*n
+	// The CRC32 of the objects are skipped.
+	pos += 4 * n
+	p.offsets, pos = idx[pos:pos+4*n], pos+4*n
+	p.large = idx[pos : len(idx)-2*20]
+
+	if p.f, err = os.Open(strings.TrimSuffix(idxPath, ".idx") + ".pack"); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *gitPack) hash(i int) []byte { return p.hashes[20*i : 20*i+20] }
+
+// find returns the offset of the object h in the pack.
+func (p *gitPack) find(h gitHash) (int64, bool) {
+	var lo, hi = 0, int(p.fanout[h[0]])
+	if h[0] > 0 {
+		lo = int(p.fanout[h[0]-1])
+	}
+	var i = lo + sort.Search(hi-lo, func(i int) bool { return bytes.Compare(p.hash(lo+i), h[:]) >= 0 })
+	if i >= hi || !bytes.Equal(p.hash(i), h[:]) {
+		return 0, false
+	}
+
+	var offset = binary.BigEndian.Uint32(p.offsets[4*i:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+	var j = int(offset &^ 0x80000000)
+	if 8*j+8 > len(p.large) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(p.large[8*j:])), true
+}
+
+// abbrev returns the objects of the pack whose name starts with the
+// hexadecimal prefix.
+func (p *gitPack) abbrev(prefix string) []gitHash {
+	var n = int(p.fanout[255])
+	var i = sort.Search(n, func(i int) bool { return hex.EncodeToString(p.hash(i)) >= prefix })
+
+	var found []gitHash
+	for ; i < n && strings.HasPrefix(hex.EncodeToString(p.hash(i)), prefix); i++ {
+		found = append(found, gitHash(p.hash(i)))
+	}
+	return found
+}
+
+// object returns the type and the contents of the object found at offset in
+// the pack, whose deltas are resolved against the objects of db.
+func (p *gitPack) object(db *gitDB, offset int64, depth int) (string, []byte, error) {
+	if depth > maxDeltaDepth {
+		return "", nil, errors.New("too many deltas in the packfile")
+	}
+	if o, ok := p.bases[offset]; ok {
+		return o.typ, o.data, nil
+	}
+
+	var r = bufio.NewReader(io.NewSectionReader(p.f, offset, 1<<62))
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", nil, err
+	}
+	var kind, size = (c >> 4) & 7, int64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return "", nil, err
+		}
+		size |= int64(c&0x7f) << shift
+	}
+
+	switch kind {
+	case packOfsDelta, packRefDelta:
+	default:
+		typ, ok := packTypes[kind]
+		if !ok {
+			return "", nil, fmt.Errorf("%s: unknown object type %d at offset %d", p.f.Name(), kind, offset)
+		}
+		data, err := inflate(r, size)
+		return typ, data, err
+	}
+
+	var typ string
+	var base []byte
+	if kind == packOfsDelta {
+		c, err := r.ReadByte()
+		var distance = int64(c & 0x7f)
+		for err == nil && c&0x80 != 0 {
+			c, err = r.ReadByte()
+			distance = (distance+1)<<7 | int64(c&0x7f)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if typ, base, err = p.object(db, offset-distance, depth+1); err != nil {
+			return "", nil, err
+		}
+		p.cache(offset-distance, typ, base)
+	} else {
+		var h gitHash
+		if _, err := io.ReadFull(r, h[:]); err != nil {
+			return "", nil, err
+		}
+		if typ, base, err = db.read(h, depth+1); err != nil {
+			return "", nil, err
+		}
+	}
+
+	delta, err := inflate(r, size)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := applyDelta(base, delta)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: offset %d: %w", p.f.Name(), offset, err)
+	}
+	return typ, data, nil
+}
+
+// cache keeps the delta base found at offset, as the objects deltified
+// against the same base are often read one after the other.
+func (p *gitPack) cache(offset int64, typ string, data []byte) {
+	if len(p.bases) >= deltaCacheSize {
+		p.bases = make(map[int64]packedObject)
+	}
+	p.bases[offset] = packedObject{typ: typ, data: data}
+}
+
+// inflate reads the zlib compressed data of size bytes from r.
+func inflate(r io.Reader, size int64) ([]byte, error) {
+	if size < 0 || size > maxObjectSize {
+		return nil, fmt.Errorf("invalid object size %d", size)
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var data = make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+var errInvalidDelta = errors.New("invalid delta")
+
+// applyDelta returns the object obtained by applying the delta to its base.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, delta := deltaSize(delta)
+	size, delta := deltaSize(delta)
+	if baseSize != uint64(len(base)) || size > maxObjectSize {
+		return nil, errInvalidDelta
+	}
+
+	var data = make([]byte, 0, size)
+	for len(delta) > 0 {
+		var op = delta[0]
+		delta = delta[1:]
+
+		switch {
+		case op&0x80 != 0:
+			// Copies a part of the base, the flags tell which bytes of its
+			// offset and size are present.
+			var offset, n uint64
+			for i := uint(0); i < 7; i++ {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errInvalidDelta
+				}
+				if i < 4 {
+					offset |= uint64(delta[0]) << (8 * i)
+				} else {
+					n |= uint64(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if offset+n > uint64(len(base)) {
+				return nil, errInvalidDelta
+			}
+			data = append(data, base[offset:offset+n]...)
+		case op != 0:
+			// Inserts the next op bytes of the delta.
+			if int(op) > len(delta) {
+				return nil, errInvalidDelta
+			}
+			data = append(data, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errInvalidDelta
+		}
+	}
+
+	if uint64(len(data)) != size {
+		return nil, errInvalidDelta
+	}
+	return data, nil
+}
+
+// deltaSize decodes a size at the start of a delta, and returns the rest of
+// the delta.
+func deltaSize(delta []byte) (uint64, []byte) {
+	var size uint64
+	for shift := uint(0); len(delta) > 0 && shift < 64; shift += 7 {
+		var c = delta[0]
+		delta = delta[1:]
+		size |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	return size, delta
+}
+
+// gitConfig returns the value of the key of the section in the config file
+// of the git directory, or "" when it isn't set.
+func gitConfig(gitDir, section, key string) string {
+	b, err := os.ReadFile(filepath.Join(gitDir, "config"))
+	if err != nil {
+		return ""
+	}
+
+	var current, value string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.ToLower(strings.TrimSpace(strings.Trim(line, "[]")))
+			continue
+		}
+		k, v, _ := strings.Cut(line, "=")
+		if current == section && strings.EqualFold(strings.TrimSpace(k), key) {
+			value = strings.ToLower(strings.TrimSpace(v))
+		}
+	}
+	return value
+}
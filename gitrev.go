@@ -0,0 +1,461 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// revTree returns the read-only tree of the files of the git revision, as
+// found in the object database of the repository of path, along with the
+// function which releases it. The tree is rooted at the directory of path
+// relative to the top of its working tree.
+func revTree(path, rev string) (tree, func() error, error) {
+	if _, err := os.Stat(path); err != nil {
+		return tree{}, nil, &Error{err: err, code: exitFailedToStatTree}
+	}
+
+	gitDir, prefix, err := findGitDir(path)
+	if err != nil {
+		return tree{}, nil, &Error{err: err, code: errFailedReadingRevision}
+	}
+	db, err := openGitDB(gitDir)
+	if err != nil {
+		return tree{}, nil, &Error{err: err, code: errFailedReadingRevision}
+	}
+
+	h, err := db.resolve(rev)
+	if err == nil {
+		h, err = db.peel(h, "tree")
+	}
+	if err != nil {
+		db.Close()
+		return tree{}, nil, &Error{err: fmt.Errorf("%s: %w", rev, err), code: errFailedReadingRevision}
+	}
+
+	var fsys = &gitFS{db: db, root: h, trees: make(map[gitHash][]gitTreeEntry)}
+	return tree{fsys: fsys, root: prefix, base: rev, sep: revSep}, db.Close, nil
+}
+
+// findGitDir returns the git directory of the repository of path, which is
+// either a bare repository or has a working tree holding path. The prefix is
+// the slash separated path of path relative to the top of the working tree.
+func findGitDir(path string) (gitDir, prefix string, err error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	if isGitDir(abs) {
+		return abs, ".", nil
+	}
+
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		var dotGit = filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			gitDir = dotGit
+			// The .git file of the linked worktrees and submodules points
+			// to their git directory.
+			if !info.IsDir() {
+				b, err := os.ReadFile(dotGit)
+				if err != nil {
+					return "", "", err
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(string(b), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+			}
+			rel, err := filepath.Rel(dir, abs)
+			return gitDir, filepath.ToSlash(rel), err
+		}
+		if filepath.Dir(dir) == dir {
+			return "", "", fmt.Errorf("%s: not a git repository", path)
+		}
+	}
+}
+
+// isGitDir returns true when dir is a git directory.
+func isGitDir(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// resolve returns the object named by rev, a revision as understood by git
+// rev-parse: a full or abbreviated object name or a ref, followed by any of
+// the ~<n>, ^<n> and ^{<type>} suffixes.
+func (db *gitDB) resolve(rev string) (gitHash, error) {
+	var i = strings.IndexAny(rev, "~^")
+	if i < 0 {
+		i = len(rev)
+	}
+	h, err := db.resolveName(rev[:i])
+	if err != nil {
+		return h, err
+	}
+
+	for s := rev[i:]; s != "" && err == nil; {
+		var op = s[0]
+		s = s[1:]
+		if op == '^' && strings.HasPrefix(s, "{") {
+			var end = strings.IndexByte(s, '}')
+			if end < 0 {
+				return h, fmt.Errorf("invalid revision %q", rev)
+			}
+			h, err = db.peel(h, s[1:end])
+			s = s[end+1:]
+			continue
+		}
+
+		var digits = len(s) - len(strings.TrimLeft(s, "0123456789"))
+		var n = 1
+		if digits > 0 {
+			if n, err = strconv.Atoi(s[:digits]); err != nil {
+				return h, fmt.Errorf("invalid revision %q", rev)
+			}
+		}
+		s = s[digits:]
+
+		switch {
+		case op == '~':
+			for ; n > 0 && err == nil; n-- {
+				h, err = db.parent(h, 1)
+			}
+		case n == 0:
+			h, err = db.peel(h, "commit")
+		default:
+			h, err = db.parent(h, n)
+		}
+	}
+	return h, err
+}
+
+// resolveName returns the object named by a full or abbreviated object name
+// or a ref, which is looked for like git does, e.g. v1.0 is refs/tags/v1.0
+// unless there's no such tag.
+func (db *gitDB) resolveName(name string) (gitHash, error) {
+	if h, ok := parseGitHash(name); ok {
+		return h, nil
+	}
+	if name == "" || name == "@" {
+		name = "HEAD"
+	}
+
+	for _, ref := range []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name, "refs/remotes/" + name + "/HEAD"} {
+		h, ok, err := db.ref(ref, 0)
+		if err != nil {
+			return h, err
+		}
+		if ok {
+			return h, nil
+		}
+	}
+
+	if len(name) >= 4 && strings.Trim(strings.ToLower(name), "0123456789abcdef") == "" {
+		return db.abbrev(strings.ToLower(name))
+	}
+	return gitHash{}, fmt.Errorf("unknown revision %q", name)
+}
+
+// ref returns the object the ref points to, following the symbolic refs. It
+// returns false when the ref doesn't exist.
+func (db *gitDB) ref(name string, depth int) (gitHash, bool, error) {
+	if depth > 5 {
+		return gitHash{}, false, fmt.Errorf("%s: too many levels of symbolic refs", name)
+	}
+	if strings.Contains(name, "..") || strings.HasPrefix(name, "/") {
+		return gitHash{}, false, fmt.Errorf("invalid ref %q", name)
+	}
+
+	for _, dir := range []string{db.gitDir, db.commonDir} {
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+
+		var text = strings.TrimSpace(string(b))
+		if target, ok := strings.CutPrefix(text, "ref:"); ok {
+			return db.ref(strings.TrimSpace(target), depth+1)
+		}
+		h, ok := parseGitHash(text)
+		if !ok {
+			return h, false, fmt.Errorf("%s: invalid ref", name)
+		}
+		return h, true, nil
+	}
+
+	b, err := os.ReadFile(filepath.Join(db.commonDir, "packed-refs"))
+	if err != nil {
+		return gitHash{}, false, nil
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		// The lines starting with ^ hold the objects the tags point to.
+		if hash, ref, ok := strings.Cut(strings.TrimSpace(line), " "); ok && ref == name {
+			h, ok := parseGitHash(hash)
+			return h, ok, nil
+		}
+	}
+	return gitHash{}, false, nil
+}
+
+// peel returns the object of the type which h leads to, following the tags
+// and the tree of the commits. An empty type only follows the tags.
+func (db *gitDB) peel(h gitHash, typ string) (gitHash, error) {
+	for {
+		got, data, err := db.object(h)
+		if err != nil {
+			return h, err
+		}
+		if got == typ || (typ == "" && got != "tag") {
+			return h, nil
+		}
+
+		var next string
+		switch {
+		case got == "tag":
+			next = objectHeader(data, "object")
+		case got == "commit" && typ == "tree":
+			next = objectHeader(data, "tree")
+		}
+		target, ok := parseGitHash(next)
+		if !ok {
+			return h, fmt.Errorf("object %s is a %s, not a %s", h, got, typ)
+		}
+		h = target
+	}
+}
+
+// parent returns the nth parent of the commit h leads to.
+func (db *gitDB) parent(h gitHash, n int) (gitHash, error) {
+	h, err := db.peel(h, "commit")
+	if err != nil {
+		return h, err
+	}
+	_, data, err := db.object(h)
+	if err != nil {
+		return h, err
+	}
+
+	var parents = objectHeaders(data, "parent")
+	if n > len(parents) {
+		return h, fmt.Errorf("commit %s has no parent %d", h, n)
+	}
+	parent, ok := parseGitHash(parents[n-1])
+	if !ok {
+		return h, fmt.Errorf("commit %s: invalid parent", h)
+	}
+	return parent, nil
+}
+
+// objectHeaders returns the values of the key in the headers of a commit or
+// a tag, which come before their message.
+func objectHeaders(data []byte, key string) []string {
+	var values []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, key+" "); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func objectHeader(data []byte, key string) string {
+	if values := objectHeaders(data, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// gitFS is the read-only fs.FS of the tree root of a git repository, whose
+// objects are read from its object database as the files are opened. The
+// symbolic links and the submodules aren't part of it.
+type gitFS struct {
+	db    *gitDB
+	root  gitHash
+	trees map[gitHash][]gitTreeEntry
+}
+
+// gitTreeEntry is a file or directory of a gitFS.
+type gitTreeEntry struct {
+	fsys *gitFS
+	name string
+	mode fs.FileMode
+	hash gitHash
+}
+
+func (e gitTreeEntry) Name() string               { return e.name }
+func (e gitTreeEntry) IsDir() bool                { return e.mode.IsDir() }
+func (e gitTreeEntry) Type() fs.FileMode          { return e.mode.Type() }
+func (e gitTreeEntry) Info() (fs.FileInfo, error) { return e.fsys.stat(e) }
+
+// gitFileInfo is the fs.FileInfo of an entry of a gitFS. The objects have no
+// modification time.
+type gitFileInfo struct {
+	gitTreeEntry
+	size int64
+}
+
+func (i gitFileInfo) Size() int64        { return i.size }
+func (i gitFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i gitFileInfo) ModTime() time.Time { return time.Time{} }
+func (i gitFileInfo) Sys() interface{}   { return nil }
+
+func (fsys *gitFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	e, err := fsys.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if e.IsDir() {
+		entries, err := fsys.tree(e.hash)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		var dirEntries = make([]fs.DirEntry, 0, len(entries))
+		for _, entry := range entries {
+			dirEntries = append(dirEntries, entry)
+		}
+		sort.Slice(dirEntries, func(i, j int) bool { return dirEntries[i].Name() < dirEntries[j].Name() })
+		return &dirFile{info: gitFileInfo{gitTreeEntry: e}, path: name, entries: dirEntries}, nil
+	}
+
+	data, err := fsys.blob(e.hash)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &gitFile{Reader: bytes.NewReader(data), info: gitFileInfo{gitTreeEntry: e, size: int64(len(data))}}, nil
+}
+
+// lookup returns the entry of the file name, following its path from the
+// root tree.
+func (fsys *gitFS) lookup(name string) (gitTreeEntry, error) {
+	var e = gitTreeEntry{fsys: fsys, name: ".", mode: fs.ModeDir | 0755, hash: fsys.root}
+	if name == "." {
+		return e, nil
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if !e.IsDir() {
+			return e, fs.ErrNotExist
+		}
+		entries, err := fsys.tree(e.hash)
+		if err != nil {
+			return e, err
+		}
+
+		var found bool
+		for _, entry := range entries {
+			if entry.name == part {
+				e, found = entry, true
+				break
+			}
+		}
+		if !found {
+			return e, fs.ErrNotExist
+		}
+	}
+	return e, nil
+}
+
+func (fsys *gitFS) stat(e gitTreeEntry) (fs.FileInfo, error) {
+	if e.IsDir() {
+		return gitFileInfo{gitTreeEntry: e}, nil
+	}
+	data, err := fsys.blob(e.hash)
+	if err != nil {
+		return nil, err
+	}
+	return gitFileInfo{gitTreeEntry: e, size: int64(len(data))}, nil
+}
+
+func (fsys *gitFS) blob(h gitHash) ([]byte, error) {
+	typ, data, err := fsys.db.object(h)
+	if err == nil && typ != "blob" {
+		err = fmt.Errorf("object %s is a %s, not a blob", h, typ)
+	}
+	return data, err
+}
+
+// tree returns the entries of the tree h, which are parsed once.
+func (fsys *gitFS) tree(h gitHash) ([]gitTreeEntry, error) {
+	if entries, ok := fsys.trees[h]; ok {
+		return entries, nil
+	}
+
+	typ, data, err := fsys.db.object(h)
+	if err == nil && typ != "tree" {
+		err = fmt.Errorf("object %s is a %s, not a tree", h, typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The entries are "<octal mode> <name>\x00<20 bytes object name>".
+	var entries []gitTreeEntry
+	for len(data) > 0 {
+		var space, nul = bytes.IndexByte(data, ' '), bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || len(data) < nul+1+20 {
+			return nil, fmt.Errorf("tree %s: invalid entry", h)
+		}
+		mode, err := strconv.ParseUint(string(data[:space]), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("tree %s: invalid mode: %w", h, err)
+		}
+		var e = gitTreeEntry{fsys: fsys, name: string(data[space+1 : nul]), hash: gitHash(data[nul+1 : nul+1+20])}
+		data = data[nul+1+20:]
+
+		switch mode & 0170000 {
+		case 0040000:
+			e.mode = fs.ModeDir | 0755
+		case 0100000:
+			e.mode = fs.FileMode(mode & 0777)
+		default:
+			// Symbolic links and submodules.
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	fsys.trees[h] = entries
+	return entries, nil
+}
+
+type gitFile struct {
+	*bytes.Reader
+	info gitFileInfo
+}
+
+func (f *gitFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *gitFile) Close() error               { return nil }
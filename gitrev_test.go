@@ -0,0 +1,272 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// gitCommit commits the files to the repository in dir, and returns the name
+// of the commit.
+func gitCommit(t *testing.T, dir, message string, files map[string]string) string {
+	writeFiles(t, dir, files)
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", message},
+	} {
+		if _, err := git(dir, nil, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gitOutput(t, dir, "rev-parse", "HEAD")
+}
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	out, err := git(dir, nil, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// testRepo returns a repository with a few commits and tags, whose files are
+// similar enough from a commit to the other to be deltified once packed.
+func testRepo(t *testing.T) string {
+	var dir = gitRepo(t, nil)
+	var body = strings.Repeat("// Some documentation of the package.\n", 200)
+	gitCommit(t, dir, "first", map[string]string{
+		"main.go":         "package main\n" + body,
+		"cmd/tool/doc.go": "package tool\n",
+	})
+	gitCommit(t, dir, "second", map[string]string{
+		"main.go":  "package main\n" + body + "\nfunc main() {}\n",
+		"README":   "readme\n",
+		"empty.go": "",
+	})
+	for _, args := range [][]string{
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "tag", "-a", "-m", "release", "v1.0"},
+		{"tag", "light"},
+	} {
+		if _, err := git(dir, nil, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitCommit(t, dir, "third", map[string]string{
+		"main.go": "package main\n" + body + "\nfunc main() { run() }\n",
+	})
+	if err := os.Symlink("main.go", filepath.Join(dir, "link.go")); err == nil {
+		gitCommit(t, dir, "symlink", nil)
+	}
+	return dir
+}
+
+func Test_gitDB_resolve(t *testing.T) {
+	var dir = testRepo(t)
+	var revs = []string{
+		"HEAD", "HEAD~1", "HEAD^", "HEAD~2", "HEAD^^", "HEAD^0", "@",
+		"v1.0", "v1.0^{}", "v1.0^{commit}", "v1.0^{tree}", "v1.0~1", "refs/tags/v1.0",
+		"light", "HEAD~1^{tree}", gitOutput(t, dir, "rev-parse", "--abbrev-ref", "HEAD"),
+		gitOutput(t, dir, "rev-parse", "--short=7", "HEAD~2"),
+	}
+
+	for _, packed := range []bool{false, true} {
+		if packed {
+			for _, args := range [][]string{{"repack", "-adfq"}, {"pack-refs", "--all"}, {"prune-packed"}} {
+				if _, err := git(dir, nil, args...); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		db, err := openGitDB(filepath.Join(dir, ".git"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if packed && len(db.packs) == 0 {
+			t.Fatal("the repository isn't packed")
+		}
+
+		for _, rev := range revs {
+			got, err := db.resolve(rev)
+			if err != nil {
+				t.Errorf("packed=%v: resolve(%q) = %v", packed, rev, err)
+				continue
+			}
+			if want := gitOutput(t, dir, "rev-parse", rev); got.String() != want {
+				t.Errorf("packed=%v: resolve(%q) = %s, want %s", packed, rev, got, want)
+			}
+		}
+
+		for _, rev := range []string{"missing", "HEAD~10", "HEAD^2", "v1.0^{blob}", "HEAD^{", "0000"} {
+			if got, err := db.resolve(rev); err == nil {
+				t.Errorf("packed=%v: resolve(%q) = %s, want an error", packed, rev, got)
+			}
+		}
+		db.Close()
+	}
+}
+
+func Test_gitFS(t *testing.T) {
+	var dir = testRepo(t)
+	for _, packed := range []bool{false, true} {
+		if packed {
+			if _, err := git(dir, nil, "gc", "-q", "--aggressive", "--prune=now"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, rev := range []string{"HEAD", "v1.0", "HEAD~2"} {
+			tr, closeRepo, err := revTree(dir, rev)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var files = strings.Fields(gitOutput(t, dir, "ls-tree", "-r", "--name-only", rev))
+			var regular []string
+			for _, name := range files {
+				if mode := gitOutput(t, dir, "ls-tree", rev, name); !strings.HasPrefix(mode, "120000") {
+					regular = append(regular, name)
+				}
+			}
+			if err := fstest.TestFS(tr.fsys, regular...); err != nil {
+				t.Errorf("packed=%v, rev=%s: %v", packed, rev, err)
+			}
+
+			for _, name := range regular {
+				got, err := fs.ReadFile(tr.fsys, name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := gitOutput(t, dir, "cat-file", "blob", rev+":"+name); strings.TrimSpace(string(got)) != want {
+					t.Errorf("packed=%v: %s:%s = %d bytes, want %d", packed, rev, name, len(got), len(want))
+				}
+			}
+			if _, err := fs.Stat(tr.fsys, "link.go"); err == nil {
+				t.Errorf("packed=%v: %s:link.go is a symbolic link and isn't part of the tree", packed, rev)
+			}
+			closeRepo()
+		}
+	}
+}
+
+func Test_run_rev(t *testing.T) {
+	header, err := renderHeader(options{license: defaultLicense, licensor: defaultLicensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dir = gitRepo(t, nil)
+	gitCommit(t, dir, "first", map[string]string{
+		"main.go":          "package main\n",
+		"cmd/tool/main.go": "package main\n",
+		"vendor/dep.go":    "package dep\n",
+	})
+	gitCommit(t, dir, "second", map[string]string{
+		"main.go":          string(header) + "\npackage main\n",
+		"cmd/tool/main.go": string(header) + "\npackage main\n",
+	})
+	// The working tree isn't read, nor rewritten.
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		rev        string
+		want       int
+		wantOutput string
+	}{
+		{name: "Compliant revision", path: dir, rev: "HEAD", want: 0},
+		{
+			name: "Revision missing headers",
+			path: dir,
+			rev:  "HEAD~1",
+			want: exitSourceNeedsToBeRewritten,
+			wantOutput: `
+HEAD~1:cmd/tool/main.go: is missing the license header
+HEAD~1:main.go: is missing the license header
+`[1:],
+		},
+		{
+			name: "Subdirectory of the working tree",
+			path: filepath.Join(dir, "cmd"),
+			rev:  "HEAD~1",
+			want: exitSourceNeedsToBeRewritten,
+			wantOutput: `
+HEAD~1:cmd/tool/main.go: is missing the license header
+`[1:],
+		},
+		{name: "Unknown revision", path: dir, rev: "v2.0", want: errFailedReadingRevision},
+		{name: "Not a repository", path: t.TempDir(), rev: "HEAD", want: errFailedReadingRevision},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf = new(bytes.Buffer)
+			var err = run(context.Background(), []string{tt.path}, options{
+				license:  defaultLicense,
+				licensor: defaultLicensor,
+				ext:      defaultExt,
+				rev:      tt.rev,
+			}, buf)
+			if got := Code(err); got != tt.want {
+				t.Errorf("run() = %v, want %v", err, tt.want)
+			}
+			if got := buf.String(); got != tt.wantOutput {
+				t.Errorf("Output = \n%v\n want \n%v", got, tt.wantOutput)
+			}
+		})
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "package main\n" {
+		t.Errorf("main.go was rewritten: %q", got)
+	}
+
+	t.Run("Bare repository", func(t *testing.T) {
+		var bare = filepath.Join(t.TempDir(), "bare.git")
+		if _, err := git(dir, nil, "clone", "-q", "--bare", dir, bare); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf = new(bytes.Buffer)
+		var err = run(context.Background(), []string{bare}, options{
+			license:  defaultLicense,
+			licensor: defaultLicensor,
+			ext:      defaultExt,
+			rev:      "HEAD~1",
+		}, buf)
+		if got := Code(err); got != exitSourceNeedsToBeRewritten {
+			t.Errorf("run() = %v, want %v", err, exitSourceNeedsToBeRewritten)
+		}
+		var wantOutput = "HEAD~1:cmd/tool/main.go: is missing the license header\nHEAD~1:main.go: is missing the license header\n"
+		if got := buf.String(); got != wantOutput {
+			t.Errorf("Output = \n%v\n want \n%v", got, wantOutput)
+		}
+	})
+}
@@ -0,0 +1,304 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hookMarker identifies the hooks installed by go-licenser, so that they can
+// be overwritten when installing again.
+const hookMarker = "# installed by go-licenser hook install"
+
+var hookUsageText = `
+Usage: go-licenser hook install [flags]
+       go-licenser hook run [flags]
+
+  install writes a git pre-commit hook which runs go-licenser hook run with the same flags. The
+  hook is written to the hooks directory of the repository, respecting core.hooksPath.
+
+  run checks the staged contents of the files about to be committed, as found in the index
+  rather than in the working tree. With -fix, the header is added to both the staged contents
+  and the working tree file, and the fixed contents are staged.
+
+Options:
+
+`[1:]
+
+type hookOptions struct {
+	options
+	dir   string
+	fix   bool
+	force bool
+	bin   string
+}
+
+// hookFlags returns the flag set of the hook command, the flags which are
+// passed through to the installed hook are defined by the caller.
+func hookFlags(opts *hookOptions, exclude *sliceFlag) *flag.FlagSet {
+	var fs = flag.NewFlagSet("hook", flag.ExitOnError)
+	fs.Var(exclude, "exclude", `path to exclude (can be specified multiple times).`)
+	fs.BoolVar(&opts.copyright, "copyright", false, "sets the copyright string as the first line")
+	fs.StringVar(&opts.ext, "ext", defaultExt, "sets the file extension to check.")
+	fs.StringVar(&opts.license, "license", defaultLicense, "sets the license type to check.")
+	fs.StringVar(&opts.licensor, "licensor", defaultLicensor, "sets the name of the licensor")
+	fs.StringVar(&opts.foreignCopyright, "foreign-copyright", foreignReplace, fmt.Sprintf("sets how the headers holding the copyright notice of a third party are handled: %s", strings.Join(foreignModes, ", ")))
+	fs.BoolVar(&opts.fix, "fix", false, "adds the missing headers and stages the fixed files instead of failing.")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), hookUsageText)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// hookCommand runs the install or run subcommands.
+func hookCommand(args []string, out io.Writer) error {
+	var opts hookOptions
+	var exclude sliceFlag
+	var fs = hookFlags(&opts, &exclude)
+	if len(args) == 0 || (args[0] != "install" && args[0] != "run") {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var sub = args[0]
+	if sub == "install" {
+		fs.BoolVar(&opts.force, "force", false, "overwrites an existing pre-commit hook.")
+		fs.StringVar(&opts.bin, "bin", "go-licenser", "sets the go-licenser binary which is invoked by the hook.")
+	}
+	fs.Parse(args[1:])
+	opts.exclude = exclude
+	opts.dir = defaultPath
+
+	if sub == "install" {
+		return hookInstall(opts, hookArgs(args[1:]), out)
+	}
+	return hookRun(opts, out)
+}
+
+// hookArgs returns the flags which are passed to the hook runner, removing
+// the ones which only apply to the installation.
+func hookArgs(args []string) []string {
+	var passed []string
+	for i := 0; i < len(args); i++ {
+		var name = strings.TrimLeft(strings.SplitN(args[i], "=", 2)[0], "-")
+		switch name {
+		case "force":
+			continue
+		case "bin":
+			if !strings.Contains(args[i], "=") {
+				i++
+			}
+			continue
+		}
+		passed = append(passed, args[i])
+	}
+	return passed
+}
+
+func hookInstall(opts hookOptions, args []string, out io.Writer) error {
+	hooks, err := git(opts.dir, nil, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return &Error{err: err, code: errFailedInstallingHook}
+	}
+
+	var dir = strings.TrimSpace(string(hooks))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(opts.dir, dir)
+	}
+	var path = filepath.Join(dir, "pre-commit")
+
+	if current, err := os.ReadFile(path); err == nil && !opts.force && !bytes.Contains(current, []byte(hookMarker)) {
+		return &Error{err: fmt.Errorf("%s already exists, use -force to overwrite it", path), code: errFailedInstallingHook}
+	}
+
+	var quoted = []string{shellQuote(opts.bin), "hook", "run"}
+	for _, a := range args {
+		quoted = append(quoted, shellQuote(a))
+	}
+	var script = fmt.Sprintf("#!/bin/sh\n%s\nexec %s\n", hookMarker, strings.Join(quoted, " "))
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return &Error{err: err, code: errFailedInstallingHook}
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		return &Error{err: err, code: errFailedInstallingHook}
+	}
+
+	fmt.Fprintf(out, "installed the pre-commit hook in %s\n", path)
+	return nil
+}
+
+// hookRun checks the staged blobs of the added, copied, modified and renamed
+// files.
+func hookRun(opts hookOptions, out io.Writer) error {
+	headerBytes, err := renderHeader(opts.options)
+	if err != nil {
+		return err
+	}
+
+	staged, err := git(opts.dir, nil, "diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z")
+	if err != nil {
+		return &Error{err: err, code: errFailedReadingIndex}
+	}
+
+	var r = &textReporter{out: out}
+	var errs Errors
+	for _, path := range strings.Split(string(staged), "\x00") {
+		if path == "" || filepath.Ext(path) != opts.ext || isExcluded(".", filepath.FromSlash(path), opts.exclude) {
+			continue
+		}
+
+		errs.Add(hookCheck(path, headerBytes, opts, r))
+	}
+
+	return errs.Err()
+}
+
+// hookCheck checks the staged contents of the file path like any other file,
+// through an in-memory tree holding them. With -fix, the fixed contents are
+// staged and the working tree file is fixed as well.
+func hookCheck(path string, headerBytes []byte, opts hookOptions, r reporter) error {
+	blob, err := git(opts.dir, nil, "cat-file", "blob", ":"+path)
+	if err != nil {
+		return &Error{err: err, code: errFailedReadingIndex}
+	}
+
+	// The staged contents are fixed rather than the working tree file, so
+	// that unstaged changes aren't committed along with the header.
+	var checkOpts = opts.options
+	checkOpts.dry = !opts.fix
+	var staged = &blobFS{name: path, data: blob}
+	if err := checkFile(fsTree(staged), path, headerBytes, checkOpts, r); err != nil || !staged.written {
+		return err
+	}
+
+	sha, err := git(opts.dir, staged.data, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return &Error{err: err, code: errFailedRewrittingFile}
+	}
+
+	entry, err := git(opts.dir, nil, "ls-files", "--stage", "-z", "--", path)
+	if err != nil {
+		return &Error{err: err, code: errFailedReadingIndex}
+	}
+	var mode = strings.SplitN(string(entry), " ", 2)[0]
+
+	var cacheinfo = fmt.Sprintf("%s,%s,%s", mode, strings.TrimSpace(string(sha)), path)
+	if _, err := git(opts.dir, nil, "update-index", "--cacheinfo", cacheinfo); err != nil {
+		return &Error{err: err, code: errFailedRewrittingFile}
+	}
+
+	// The working tree file may have been deleted since it was staged.
+	var worktree = osTree(opts.dir)
+	if _, err := fs.Stat(worktree.fsys, path); err == nil {
+		if err := checkFile(worktree, path, headerBytes, checkOpts, r); err != nil {
+			return err
+		}
+	}
+
+	r.Report(finding{Path: path, Kind: findingMissingHeader, Message: "was fixed and staged"})
+	return nil
+}
+
+// checkFile checks or fixes the file name of the tree.
+func checkFile(t tree, name string, headerBytes []byte, opts options, r reporter) error {
+	info, err := fs.Stat(t.fsys, name)
+	if err != nil {
+		return &Error{err: err, code: exitFailedToStatFile}
+	}
+	return addOrCheckLicense(t, name, headerBytes, fs.FileInfoToDirEntry(info), opts, r, nil, nil)
+}
+
+// blobFS is the in-memory tree of a single file holding a staged blob. The
+// file can be rewritten, in which case written is set.
+type blobFS struct {
+	name    string
+	data    []byte
+	written bool
+}
+
+func (b *blobFS) Open(name string) (fs.File, error) {
+	if name != b.name {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &blobFile{Reader: bytes.NewReader(b.data), name: name}, nil
+}
+
+func (b *blobFS) WriteFile(name string, perm fs.FileMode, write func(io.Writer) error) error {
+	if name != b.name {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrNotExist}
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	b.data, b.written = buf.Bytes(), true
+	return nil
+}
+
+// blobFile is an open file of a blobFS, which is its own fs.FileInfo.
+type blobFile struct {
+	*bytes.Reader
+	name string
+}
+
+func (f *blobFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *blobFile) Close() error               { return nil }
+func (f *blobFile) Name() string               { return path.Base(f.name) }
+func (f *blobFile) Mode() fs.FileMode          { return 0644 }
+func (f *blobFile) ModTime() time.Time         { return time.Time{} }
+func (f *blobFile) IsDir() bool                { return false }
+func (f *blobFile) Sys() interface{}           { return nil }
+
+// git runs a git command in dir, passing stdin to it, and returns its output.
+func git(dir string, stdin []byte, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
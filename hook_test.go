@@ -0,0 +1,217 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// gitRepo initializes a temporary repository with the files staged, and
+// returns its path.
+func gitRepo(t *testing.T, files map[string]string) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	var dir = t.TempDir()
+	if _, err := git(dir, nil, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, dir, files)
+	if _, err := git(dir, nil, "add", "-A"); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func Test_hookRun(t *testing.T) {
+	var opts = hookOptions{options: options{
+		license:  defaultLicense,
+		licensor: defaultLicensor,
+		ext:      defaultExt,
+		exclude:  []string{"excluded"},
+	}}
+	headerBytes, err := renderHeader(opts.options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.dir = gitRepo(t, map[string]string{
+		"missing.go":       "package main\n",
+		"ok.go":            string(headerBytes) + "\npackage main\n",
+		"excluded/file.go": "package excluded\n",
+		"ignored.go":       "// go-licenser:ignore\npackage main\n",
+		"README.md":        "# readme\n",
+	})
+
+	// The working tree contents differ from the staged ones, only the staged
+	// contents are checked.
+	var path = filepath.Join(opts.dir, "missing.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc unstaged() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(opts.dir, "ok.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf = new(bytes.Buffer)
+	if got := Code(hookRun(opts, buf)); got != exitSourceNeedsToBeRewritten {
+		t.Errorf("hookRun() = %v, want %v", got, exitSourceNeedsToBeRewritten)
+	}
+	if got, want := buf.String(), "missing.go: is missing the license header\n"; got != want {
+		t.Errorf("Output = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	opts.fix = true
+	if err := hookRun(opts, buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "missing.go: was fixed and staged\n"; got != want {
+		t.Errorf("Output = %q, want %q", got, want)
+	}
+
+	staged, err := git(opts.dir, nil, "cat-file", "blob", ":missing.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(headerBytes) + "\npackage main\n"; string(staged) != want {
+		t.Errorf("staged contents = \n%s\n want \n%s", staged, want)
+	}
+
+	worktree, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(headerBytes) + "\npackage main\n\nfunc unstaged() {}\n"; string(worktree) != want {
+		t.Errorf("working tree contents = \n%s\n want \n%s", worktree, want)
+	}
+
+	buf.Reset()
+	opts.fix = false
+	if err := hookRun(opts, buf); err != nil || buf.Len() > 0 {
+		t.Errorf("hookRun() = %v, %q after fixing", err, buf.String())
+	}
+}
+
+func Test_hookInstall(t *testing.T) {
+	var dir = gitRepo(t, nil)
+	var opts = hookOptions{options: options{license: defaultLicense}, dir: dir, bin: "go-licenser"}
+	var args = []string{"-license", "Elastic", "-exclude", "it's"}
+
+	if err := hookInstall(opts, args, new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+
+	var want = `
+#!/bin/sh
+# installed by go-licenser hook install
+exec 'go-licenser' hook run '-license' 'Elastic' '-exclude' 'it'\''s'
+`[1:]
+	got, err := os.ReadFile(filepath.Join(dir, ".git", "hooks", "pre-commit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("hook = \n%s\n want \n%s", got, want)
+	}
+
+	// Installing again overwrites the hook installed by go-licenser.
+	if err := hookInstall(opts, nil, new(bytes.Buffer)); err != nil {
+		t.Errorf("hookInstall() = %v over its own hook", err)
+	}
+
+	if _, err := git(dir, nil, "config", "core.hooksPath", ".githooks"); err != nil {
+		t.Fatal(err)
+	}
+	var custom = filepath.Join(dir, ".githooks", "pre-commit")
+	if err := os.MkdirAll(filepath.Dir(custom), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(custom, []byte("#!/bin/sh\nmake lint\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Code(hookInstall(opts, nil, new(bytes.Buffer))); got != errFailedInstallingHook {
+		t.Errorf("hookInstall() = %v over an existing hook, want %v", got, errFailedInstallingHook)
+	}
+
+	opts.force = true
+	if err := hookInstall(opts, nil, new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(custom); !strings.Contains(string(got), hookMarker) {
+		t.Errorf("hook in core.hooksPath = \n%s", got)
+	}
+}
+
+func Test_hookArgs(t *testing.T) {
+	var got = hookArgs([]string{"-force", "-bin", "/usr/bin/go-licenser", "-license", "Elastic", "--bin=x", "-fix"})
+	if want := []string{"-license", "Elastic", "-fix"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hookArgs() = %v, want %v", got, want)
+	}
+}
+
+func Test_hookRun_misplaced(t *testing.T) {
+	var opts = hookOptions{options: options{
+		license:  defaultLicense,
+		licensor: defaultLicensor,
+		ext:      defaultExt,
+	}}
+	headerBytes, err := renderHeader(opts.options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want = string(headerBytes) + "\npackage main\n\nvar x = 1\n"
+	opts.dir = gitRepo(t, map[string]string{
+		"misplaced.go": "package main\n\n" + string(headerBytes) + "\nvar x = 1\n",
+	})
+
+	var buf = new(bytes.Buffer)
+	if got := Code(hookRun(opts, buf)); got != exitSourceNeedsToBeRewritten {
+		t.Errorf("hookRun() = %v, want %v", got, exitSourceNeedsToBeRewritten)
+	}
+	if got, want := buf.String(), "misplaced.go: has the license header at line 3 instead of the top of the file\n"; got != want {
+		t.Errorf("Output = %q, want %q", got, want)
+	}
+
+	opts.fix = true
+	if err := hookRun(opts, new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := git(opts.dir, nil, "cat-file", "blob", ":misplaced.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(staged) != want {
+		t.Errorf("staged contents = \n%s\n want \n%s", staged, want)
+	}
+	if worktree, _ := os.ReadFile(filepath.Join(opts.dir, "misplaced.go")); string(worktree) != want {
+		t.Errorf("working tree contents = \n%s\n want \n%s", worktree, want)
+	}
+}
@@ -0,0 +1,157 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+// isLicenseFile returns true when the file name is one of the well known
+// license file names.
+func isLicenseFile(name string) bool {
+	return stringInSlice(name, licenseFileNames)
+}
+
+// checkLicenseFiles checks the license files of the tree against the license
+// of the headers found in their directory and its subdirectories, down to the
+// subdirectories holding their own license file. The files which fail to be
+// read are left to the walk, which reports them.
+func checkLicenseFiles(t tree, opts options, r reporter) error {
+	var excluded = newExclusions(t.path(t.root), opts.exclude)
+	var licenseFiles []string
+	var licenseDirs = make(map[string]bool)
+	// headers counts the files of each directory by the license of their
+	// header.
+	var headers = make(map[string]map[string]int)
+	fs.WalkDir(t.fsys, t.root, func(name string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		var excludedDir = name != t.root && info.IsDir() && stringInSlice(info.Name(), defaultExludedDirs)
+		if t.excluded(excluded, name) || excludedDir {
+			if info.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case info.IsDir():
+		case isLicenseFile(info.Name()):
+			licenseFiles = append(licenseFiles, name)
+			licenseDirs[path.Dir(name)] = true
+		case filepath.Ext(name) == opts.ext:
+			preamble, err := readPreamble(t, name)
+			if err != nil {
+				return nil
+			}
+			if license := headerLicense(preamble, opts); license != "" {
+				var dir = path.Dir(name)
+				if headers[dir] == nil {
+					headers[dir] = make(map[string]int)
+				}
+				headers[dir][license]++
+			}
+		}
+		return nil
+	})
+
+	// The headers of a directory count for the closest license file above
+	// it, or in it.
+	var subtrees = make(map[string]map[string]int)
+	for dir, counts := range headers {
+		for d := dir; ; d = path.Dir(d) {
+			if licenseDirs[d] {
+				if subtrees[d] == nil {
+					subtrees[d] = make(map[string]int)
+				}
+				for license, n := range counts {
+					subtrees[d][license] += n
+				}
+				break
+			}
+			if d == "." || d == "/" {
+				break
+			}
+		}
+	}
+
+	var errs Errors
+	for _, name := range licenseFiles {
+		errs.Add(checkLicenseFile(t, name, subtreeLicense(subtrees[path.Dir(name)], opts.license), r))
+	}
+	return errs.Err()
+}
+
+// subtreeLicense returns the license of the most headers of a subtree, from
+// their counts, or the license type when there's no header. Ties go to the
+// license type, then to the first license by name.
+func subtreeLicense(counts map[string]int, license string) string {
+	var best, most = license, counts[license]
+	var licenses = make([]string, 0, len(counts))
+	for l := range counts {
+		licenses = append(licenses, l)
+	}
+	sort.Strings(licenses)
+	for _, l := range licenses {
+		if counts[l] > most {
+			best, most = l, counts[l]
+		}
+	}
+	return best
+}
+
+// checkLicenseFile classifies the full text of the license file name of the
+// tree and reports it when it doesn't match the license of the headers.
+func checkLicenseFile(t tree, name, license string, r reporter) error {
+	text, err := fs.ReadFile(t.fsys, name)
+	if err != nil {
+		return &Error{err: err, code: exitFailedToOpenWalkFile}
+	}
+
+	var want = licensing.SPDX[license]
+	if got := licensing.Classify(text); got != want {
+		reportFile(r, t.path(name), findingLicenseFileMismatch, fmt.Sprintf("is licensed under %s but the headers are %s", got, want))
+		return &Error{code: exitLicenseFileMismatch}
+	}
+
+	return nil
+}
+
+// checkRootLicenseFile reports when the root directory of the tree doesn't
+// contain any license file.
+func checkRootLicenseFile(t tree, r reporter) error {
+	if info, err := fs.Stat(t.fsys, t.root); err != nil || !info.IsDir() {
+		return nil
+	}
+
+	for _, name := range licenseFileNames {
+		if _, err := fs.Stat(t.fsys, path.Join(t.root, name)); err == nil {
+			return nil
+		}
+	}
+
+	reportFile(r, t.path(t.root), findingMissingLicenseFile, "is missing the LICENSE file")
+	return &Error{code: exitLicenseFileMismatch}
+}
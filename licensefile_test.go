@@ -0,0 +1,148 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testApacheText  = "Apache License\nVersion 2.0, January 2004\n"
+	testElasticText = "Elastic License 2.0\n\nURL: https://www.elastic.co/licensing/elastic-license\n"
+)
+
+func Test_run_licenseFiles(t *testing.T) {
+	var render = func(license string) string {
+		header, err := renderHeader(options{license: license, licensor: defaultLicensor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(header)
+	}
+	var testASL2Src = render(defaultLicense) + "\npackage main\n"
+	var testElasticv2Src = render("Elasticv2") + "// go-licenser:license=Elasticv2\n\npackage main\n"
+
+	tests := []struct {
+		name       string
+		files      map[string]string
+		exclude    []string
+		ext        string
+		license    string
+		want       int
+		wantOutput string
+	}{
+		{
+			name: "Matching LICENSE files pass",
+			files: map[string]string{
+				"LICENSE":         testApacheText,
+				"x-pack/LICENSE":  testElasticText,
+				"cloud/COPYING":   testApacheText,
+				"multilevel/NONE": testElasticText,
+			},
+			exclude: []string{"x-pack"},
+			license: defaultLicense,
+			want:    0,
+		},
+		{
+			name: "Mismatching LICENSE files are reported",
+			files: map[string]string{
+				"LICENSE.txt":    testApacheText,
+				"x-pack/LICENSE": testElasticText,
+			},
+			license: defaultLicense,
+			want:    exitLicenseFileMismatch,
+			wantOutput: `
+x-pack/LICENSE: is licensed under Elastic-2.0 but the headers are Apache-2.0
+`[1:],
+		},
+		{
+			name: "Subtree LICENSE files match the headers of their subtree",
+			files: map[string]string{
+				"LICENSE":               testApacheText,
+				"main.src":              testASL2Src,
+				"x-pack/LICENSE":        testElasticText,
+				"x-pack/main.src":       testElasticv2Src,
+				"x-pack/sub/main.src":   testElasticv2Src,
+				"x-pack/oss/LICENSE":    testApacheText,
+				"x-pack/oss/main.src":   testASL2Src,
+				"x-pack/oss/README.src": "readme\n// go-licenser:ignore\n",
+			},
+			ext:     ".src",
+			license: defaultLicense,
+			want:    0,
+		},
+		{
+			name: "Subtree LICENSE files mismatching the headers of their subtree are reported",
+			files: map[string]string{
+				"LICENSE":            testApacheText,
+				"main.src":           testASL2Src,
+				"x-pack/LICENSE":     testApacheText,
+				"x-pack/main.src":    testElasticv2Src,
+				"x-pack/oss/LICENSE": testElasticText,
+				"x-pack/oss/x.src":   testASL2Src,
+			},
+			ext:     ".src",
+			license: defaultLicense,
+			want:    exitLicenseFileMismatch,
+			wantOutput: `
+x-pack/LICENSE: is licensed under Apache-2.0 but the headers are Elastic-2.0
+x-pack/oss/LICENSE: is licensed under Elastic-2.0 but the headers are Apache-2.0
+`[1:],
+		},
+		{
+			name: "Missing root LICENSE file is reported",
+			files: map[string]string{
+				"x-pack/LICENSE": testElasticText,
+			},
+			license: "Elasticv2",
+			want:    exitLicenseFileMismatch,
+			wantOutput: `
+.: is missing the LICENSE file
+`[1:],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ext = tt.ext
+			if ext == "" {
+				ext = ".none"
+			}
+
+			var buf = new(bytes.Buffer)
+			var err = runFS(context.Background(), fixturesFS(t, tt.files), options{
+				license:      tt.license,
+				licensor:     defaultLicensor,
+				exclude:      tt.exclude,
+				ext:          ext,
+				dry:          true,
+				licenseFiles: true,
+			}, buf)
+			if got := Code(err); got != tt.want {
+				t.Errorf("run() = %v, want %v", got, tt.want)
+			}
+
+			var wantOutput = filepath.FromSlash(tt.wantOutput)
+			if got := buf.String(); got != wantOutput {
+				t.Errorf("Output = \n%v\n want \n%v", got, wantOutput)
+			}
+		})
+	}
+}
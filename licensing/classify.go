@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Unknown is the identifier returned by Classify when the license text
+// can't be matched against any of the known licenses.
+const Unknown = "UNKNOWN"
+
+// SPDX maps the licenses of the Default registry to the SPDX identifier of the license they
+// refer to. Licenses without an official SPDX identifier use LicenseRef-.
+var SPDX = map[string]string{
+	"ASL2":       "Apache-2.0",
+	"ASL2-Short": "Apache-2.0",
+	"Elastic":    "LicenseRef-Elastic",
+	"Elasticv2":  "Elastic-2.0",
+	"Cloud":      "LicenseRef-Elastic-Cloud",
+}
+
+// signature is a set of phrases which must all be present in the normalized
+// text of a license for it to be considered a match. The first phrase is the
+// title of the license, or the sentence its text starts with. When any of the
+// phrases in exclude is present, the signature doesn't match.
+type signature struct {
+	id      string
+	phrases []string
+	exclude []string
+}
+
+// signatures which match at the same position are picked in order, more
+// specific licenses must come before the ones which share their title (e.g.
+// BSD-3-Clause before BSD-2-Clause).
+var signatures = []signature{
+	{id: "AGPL-3.0", phrases: []string{"gnu affero general public license version 3"}},
+	{id: "LGPL-3.0", phrases: []string{"gnu lesser general public license version 3"}},
+	{id: "LGPL-2.1", phrases: []string{"gnu lesser general public license version 2.1"}},
+	{id: "LGPL-2.0", phrases: []string{"gnu library general public license version 2"}},
+	{id: "GPL-3.0", phrases: []string{"gnu general public license version 3"}},
+	{id: "GPL-2.0", phrases: []string{"gnu general public license version 2"}},
+	{id: "MPL-2.0", phrases: []string{"mozilla public license version 2.0"}},
+	{id: "EPL-2.0", phrases: []string{"eclipse public license - v 2.0"}},
+	{id: "EPL-1.0", phrases: []string{"eclipse public license - v 1.0"}},
+	{id: "Elastic-2.0", phrases: []string{"elastic license 2.0"}},
+	{id: "LicenseRef-Elastic", phrases: []string{"elastic license"}},
+	{id: "LicenseRef-Elastic-Cloud", phrases: []string{"elasticsearch confidential"}},
+	{id: "Apache-2.0", phrases: []string{"apache license", "version 2.0"}},
+	{id: "BSL-1.0", phrases: []string{"boost software license - version 1.0"}},
+	{id: "CC0-1.0", phrases: []string{"cc0 1.0 universal"}},
+	{id: "Unlicense", phrases: []string{"this is free and unencumbered software released into the public domain"}},
+	{id: "ISC", phrases: []string{"permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted"}},
+	{id: "MIT", phrases: []string{"permission is hereby granted, free of charge, to any person obtaining a copy"}},
+	{
+		id:      "BSD-3-Clause",
+		phrases: []string{"redistribution and use in source and binary forms", "neither the name"},
+	},
+	{
+		id:      "BSD-3-Clause",
+		phrases: []string{"redistribution and use in source and binary forms", "the names of its contributors may not be used"},
+	},
+	{
+		id:      "BSD-2-Clause",
+		phrases: []string{"redistribution and use in source and binary forms"},
+		exclude: []string{"neither the name", "may not be used to endorse"},
+	},
+}
+
+// Classify inspects the full text of a license and returns the SPDX
+// identifier for the license it contains, or Unknown when it can't be
+// determined. Since the text of a license may mention other ones, like the
+// MPL-2.0 and GPL-3.0 texts mention the GNU Affero General Public License,
+// the license whose title comes first in the text wins.
+func Classify(text []byte) string {
+	var normalized = normalize(string(text))
+	var id, at = Unknown, -1
+	for _, s := range signatures {
+		if i, ok := s.match(normalized); ok && (at < 0 || i < at) {
+			id, at = s.id, i
+		}
+	}
+
+	return id
+}
+
+// Known returns true when id is the SPDX identifier of one of the licenses
+// which Classify recognizes.
+func Known(id string) bool {
+	for _, s := range signatures {
+		if s.id == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// match returns the position of the title of the license in text when all
+// the phrases of the signature are found in it.
+func (s signature) match(text string) (int, bool) {
+	var at = strings.Index(text, s.phrases[0])
+	if at < 0 {
+		return 0, false
+	}
+	for _, p := range s.phrases[1:] {
+		if !strings.Contains(text, p) {
+			return 0, false
+		}
+	}
+
+	for _, p := range s.exclude {
+		if strings.Contains(text, p) {
+			return 0, false
+		}
+	}
+
+	return at, true
+}
+
+// normalize lowercases the text, strips comment markers and collapses all
+// whitespace into single spaces so that wrapping and comment styles don't
+// affect the classification.
+func normalize(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+
+	var space = true
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "/#*")
+		for _, r := range line {
+			if unicode.IsSpace(r) {
+				if !space {
+					b.WriteByte(' ')
+				}
+				space = true
+				continue
+			}
+			b.WriteRune(unicode.ToLower(r))
+			space = false
+		}
+		if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,113 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "empty text is unknown",
+			want: Unknown,
+		},
+		{
+			name: "Elastic License 2.0 header",
+			text: strings.Join(Headers["Elasticv2"], "\n"),
+			want: "Elastic-2.0",
+		},
+		{
+			name: "Elastic License header",
+			text: strings.Join(Headers["Elastic"], "\n"),
+			want: "LicenseRef-Elastic",
+		},
+		{
+			name: "Cloud header",
+			text: strings.Join(Headers["Cloud"], "\n"),
+			want: "LicenseRef-Elastic-Cloud",
+		},
+		{
+			name: "unrelated text is unknown",
+			text: "this is a readme file",
+			want: Unknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify([]byte(tt.text)); got != tt.want {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestClassify_texts classifies the complete texts of the licenses found in
+// testdata/licenses, which are named after their SPDX identifier. The texts
+// of licenses mention others, e.g. the GPL-3.0 and MPL-2.0 ones mention the
+// GNU Affero General Public License, version 3.
+func TestClassify_texts(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "licenses", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no license texts found")
+	}
+
+	for _, path := range paths {
+		var want = strings.TrimSuffix(filepath.Base(path), ".txt")
+		t.Run(want, func(t *testing.T) {
+			text, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Classify(text); got != want {
+				t.Errorf("Classify() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSPDX(t *testing.T) {
+	for _, k := range Default.Keys() {
+		if _, ok := SPDX[k]; !ok {
+			t.Errorf("license %s has no SPDX identifier", k)
+		}
+	}
+}
+
+func TestKnown(t *testing.T) {
+	for _, id := range []string{"MIT", "BSD-3-Clause", "Apache-2.0", "Elastic-2.0"} {
+		if !Known(id) {
+			t.Errorf("Known(%q) = false, want true", id)
+		}
+	}
+	for _, id := range []string{"", "ASL2", "mit", Unknown} {
+		if Known(id) {
+			t.Errorf("Known(%q) = true, want false", id)
+		}
+	}
+}
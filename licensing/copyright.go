@@ -0,0 +1,67 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// copyrightPrefix matches the words and years which precede the holder in a
+// copyright line, e.g. "Copyright (c) 2015-2018, ".
+var copyrightPrefix = regexp.MustCompile(`^(?i:copyright|\(c\)|©|[0-9]{4}(\s*[-,]\s*[0-9]{4})*,?)\s*`)
+
+// CopyrightHolders returns the holders of the copyright lines found in the
+// header which RewriteWithHeader would replace in src.
+func CopyrightHolders(src []byte) []string {
+	var holders []string
+	var scanner = bufio.NewScanner(bytes.NewReader(headerBytes(bytes.NewReader(bytes.TrimPrefix(src, bom)))))
+	for scanner.Scan() {
+		var line = strings.TrimSpace(strings.TrimLeft(scanner.Text(), "/#* \t"))
+		if !strings.HasPrefix(strings.ToLower(line), "copyright ") {
+			continue
+		}
+
+		for loc := copyrightPrefix.FindStringIndex(line); loc != nil && loc[1] > 0; loc = copyrightPrefix.FindStringIndex(line) {
+			line = line[loc[1]:]
+		}
+		if i := strings.Index(strings.ToLower(line), "all rights reserved"); i >= 0 {
+			line = line[:i]
+		}
+
+		if holder := strings.TrimRight(line, " .,"); holder != "" {
+			holders = append(holders, holder)
+		}
+	}
+
+	return holders
+}
+
+// PrependHeader returns src with the header inserted above its contents,
+// leaving any existing header in place.
+func PrependHeader(src []byte, header []byte) []byte {
+	return preserveFormat(src, func(src []byte) []byte {
+		var out = append([]byte(nil), header...)
+		for !bytes.HasSuffix(out, []byte("\n\n")) {
+			out = append(out, '\n')
+		}
+		return append(out, src...)
+	})
+}
@@ -0,0 +1,76 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCopyrightHolders(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "No header",
+			src:  "package main\n",
+		},
+		{
+			name: "Apache header has no copyright lines",
+			src:  strings.Join(Headers["ASL2"], "\n") + "\n\npackage main\n",
+		},
+		{
+			name: "Elastic header",
+			src:  strings.Join(Headers["Elastic"], "\n") + "\n\npackage main\n",
+			want: []string{"Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one"},
+		},
+		{
+			name: "Years, symbols and reserved rights are stripped",
+			src: `
+// Copyright (c) 2009-2015, 2018 The Go Authors. All rights reserved.
+// Copyright © 2020 Someone Else.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package main is copyright 2021 Not In The Header.
+package main
+`[1:],
+			want: []string{"The Go Authors", "Someone Else"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CopyrightHolders([]byte(tt.src)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CopyrightHolders() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrependHeader(t *testing.T) {
+	var src = "// Copyright 2015 Someone Else.\n\npackage main\n"
+	var want = "// Header\n\n" + src
+	for _, header := range []string{"// Header", "// Header\n", "// Header\n\n"} {
+		if got := string(PrependHeader([]byte(src), []byte(header))); got != want {
+			t.Errorf("PrependHeader(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
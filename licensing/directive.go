@@ -0,0 +1,111 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	directivePrefix = "go-licenser:"
+
+	// DirectiveLines is the number of lines at the top of a file in which
+	// the directives are looked for.
+	DirectiveLines = 20
+)
+
+// Directives are the go-licenser directives found at the top of a file, which
+// opt it out of the license header check:
+//
+//	// go-licenser:ignore [reason]
+//	// go-licenser:license=<license> [reason]
+//
+// The license is either one of the Default keys or the SPDX identifier of a
+// license which Classify recognizes.
+type Directives struct {
+	Ignore  bool
+	License string
+	Reason  string
+
+	// Problems describes the malformed directives.
+	Problems []string
+}
+
+// Present returns true when the file holds any directive, malformed or not.
+func (d Directives) Present() bool {
+	return d.Ignore || d.License != "" || len(d.Problems) > 0
+}
+
+// ParseDirectives reads the directives found in the first DirectiveLines of
+// r, in any of the usual comment styles.
+func ParseDirectives(r io.Reader) Directives {
+	var d Directives
+	var s = bufio.NewScanner(r)
+	for line := 1; line <= DirectiveLines && s.Scan(); line++ {
+		// The byte order mark of UTF-8 can only start the first line.
+		var text = strings.TrimSpace(strings.TrimPrefix(s.Text(), "\ufeff"))
+		for _, prefix := range []string{"//", "#", "/*", "*"} {
+			text = strings.TrimSpace(strings.TrimPrefix(text, prefix))
+		}
+		text = strings.TrimSpace(strings.TrimSuffix(text, "*/"))
+
+		if strings.HasPrefix(text, directivePrefix) {
+			d.parse(line, strings.TrimPrefix(text, directivePrefix))
+		}
+	}
+
+	if d.Ignore && d.License != "" {
+		d.Problems = append(d.Problems, "go-licenser:ignore and go-licenser:license directives can't be combined")
+	}
+	return d
+}
+
+func (d *Directives) parse(line int, text string) {
+	var name, reason = text, ""
+	if i := strings.IndexAny(text, " \t"); i >= 0 {
+		name, reason = text[:i], strings.TrimSpace(text[i+1:])
+	}
+
+	switch {
+	case name == "ignore":
+		if d.Ignore {
+			d.Problems = append(d.Problems, fmt.Sprintf("line %d: duplicate go-licenser:ignore directive", line))
+			return
+		}
+		d.Ignore = true
+	case strings.HasPrefix(name, "license="):
+		var license = strings.TrimPrefix(name, "license=")
+		if _, ok := Default.Lookup(license); !ok && !Known(license) {
+			d.Problems = append(d.Problems, fmt.Sprintf("line %d: unknown license %q in go-licenser:license directive", line, license))
+			return
+		}
+		if d.License != "" {
+			d.Problems = append(d.Problems, fmt.Sprintf("line %d: duplicate go-licenser:license directive", line))
+			return
+		}
+		d.License = license
+	default:
+		d.Problems = append(d.Problems, fmt.Sprintf("line %d: unknown directive go-licenser:%s", line, name))
+		return
+	}
+
+	d.Reason = reason
+}
@@ -0,0 +1,85 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDirectives(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Directives
+	}{
+		{
+			name: "No directives",
+			text: "package main\n",
+		},
+		{
+			name: "Ignore with a reason",
+			text: "// go-licenser:ignore copied from golang.org/x/net\npackage main\n",
+			want: Directives{Ignore: true, Reason: "copied from golang.org/x/net"},
+		},
+		{
+			name: "License in other comment styles",
+			text: "#!/bin/sh\n# go-licenser:license=MIT\n",
+			want: Directives{License: "MIT"},
+		},
+		{
+			name: "Ignore after a byte order mark",
+			text: "\ufeff// go-licenser:ignore\npackage main\n",
+			want: Directives{Ignore: true},
+		},
+		{
+			name: "License in a block comment",
+			text: "/* go-licenser:license=Elastic */\n",
+			want: Directives{License: "Elastic"},
+		},
+		{
+			name: "Directives past the first lines are ignored",
+			text: strings.Repeat("\n", DirectiveLines) + "// go-licenser:ignore\n",
+		},
+		{
+			name: "Malformed directives",
+			text: "// go-licenser:license=WTFPL\n// go-licenser:license=\n// go-licenser:skip\n",
+			want: Directives{Problems: []string{
+				`line 1: unknown license "WTFPL" in go-licenser:license directive`,
+				`line 2: unknown license "" in go-licenser:license directive`,
+				"line 3: unknown directive go-licenser:skip",
+			}},
+		},
+		{
+			name: "Duplicate and conflicting directives",
+			text: "// go-licenser:ignore\n// go-licenser:ignore\n// go-licenser:license=MIT\n",
+			want: Directives{Ignore: true, License: "MIT", Problems: []string{
+				"line 2: duplicate go-licenser:ignore directive",
+				"go-licenser:ignore and go-licenser:license directives can't be combined",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseDirectives(strings.NewReader(tt.text)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDirectives() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"bytes"
+	"sort"
+)
+
+// StackedHeaders looks for the license headers stacked below the header lines
+// at the top of src: more copies of the header, or the headers of any license
+// of the registry, in their current or former wording, rendered with the
+// params. It returns the keys of the licenses of the stacked headers, along
+// with the offsets in src where they start and end.
+func (r *Registry) StackedHeaders(src []byte, header []string, p Params) (found []string, start, end int) {
+	type candidate struct {
+		name  string
+		lines []string
+	}
+
+	var candidates []candidate
+	for _, k := range r.Keys() {
+		lines, _ := r.Render(k, p)
+		candidates = append(candidates, candidate{name: k, lines: lines})
+		for _, v := range r.Variants(k) {
+			if len(v.Lines) == 0 {
+				continue
+			}
+			candidates = append(candidates, candidate{name: k + " (variant " + v.Name + ")", lines: v.Render(p.Licensor)})
+		}
+	}
+	// The longest headers come first, so that a header which starts with the
+	// lines of another one is removed as a whole.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return len(candidates[i].lines) > len(candidates[j].lines)
+	})
+
+	start = lineOffset(src, len(header))
+	end = start
+	for {
+		var next = end
+		for next < len(src) && (src[next] == '\n' || src[next] == '\r') {
+			next++
+		}
+
+		var matched bool
+		for _, c := range candidates {
+			if ContainsHeader(bytes.NewReader(src[next:]), c.lines) {
+				found = append(found, c.name)
+				end, matched = next+lineOffset(src[next:], len(c.lines)), true
+				break
+			}
+		}
+		if !matched {
+			return found, start, end
+		}
+	}
+}
+
+// CollapseHeaders returns src without the stacked headers found between the
+// start and end offsets returned by StackedHeaders, keeping a blank line
+// below the header.
+func CollapseHeaders(src []byte, start, end int) []byte {
+	var out = append([]byte(nil), src[:start]...)
+	out = append(out, detectFormat(src).newline()...)
+	return append(out, bytes.TrimLeft(src[end:], "\r\n")...)
+}
@@ -0,0 +1,85 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_StackedHeaders(t *testing.T) {
+	var params = Params{Licensor: DefaultLicensor}
+	var render = func(key string) string {
+		lines, err := Default.Render(key, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+	var asl2, elastic = render("ASL2"), render("Elastic")
+
+	tests := []struct {
+		name      string
+		src       string
+		want      []string
+		wantBelow string
+	}{
+		{
+			name:      "Single header",
+			src:       asl2 + "\npackage main\n",
+			wantBelow: "\npackage main\n",
+		},
+		{
+			name:      "Repeated header",
+			src:       asl2 + "\n" + asl2 + "\npackage main\n",
+			want:      []string{"ASL2"},
+			wantBelow: "\npackage main\n",
+		},
+		{
+			name:      "Conflicting headers without blank lines",
+			src:       asl2 + elastic + asl2 + "package main\n",
+			want:      []string{"Elastic", "ASL2"},
+			wantBelow: "package main\n",
+		},
+		{
+			name:      "Third party notice is kept",
+			src:       asl2 + "\n// Copyright 2012 The Go Authors.\n\npackage main\n",
+			wantBelow: "\n// Copyright 2012 The Go Authors.\n\npackage main\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header = strings.Split(strings.TrimSuffix(asl2, "\n"), "\n")
+			found, start, end := Default.StackedHeaders([]byte(tt.src), header, params)
+			if !reflect.DeepEqual(found, tt.want) {
+				t.Errorf("StackedHeaders() = %v, want %v", found, tt.want)
+			}
+			if start != len(asl2) {
+				t.Errorf("StackedHeaders() start = %d, want %d", start, len(asl2))
+			}
+			if got := tt.src[end:]; got != tt.wantBelow {
+				t.Errorf("StackedHeaders() leaves %q, want %q", got, tt.wantBelow)
+			}
+
+			if got, want := string(CollapseHeaders([]byte(tt.src), start, end)), asl2+"\n"+strings.TrimLeft(tt.wantBelow, "\n"); len(found) > 0 && got != want {
+				t.Errorf("CollapseHeaders() = \n%s\n want \n%s", got, want)
+			}
+		})
+	}
+}
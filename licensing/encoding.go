@@ -0,0 +1,81 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrUTF16 is returned when rewriting UTF-16 encoded contents, which aren't
+// supported.
+var ErrUTF16 = errors.New("UTF-16 encoded files aren't supported")
+
+// bom is the UTF-8 byte order mark, which some editors write at the start of
+// the files.
+var bom = []byte("\xef\xbb\xbf")
+
+// IsUTF16 reports whether src starts with a UTF-16 byte order mark.
+func IsUTF16(src []byte) bool {
+	return bytes.HasPrefix(src, []byte{0xfe, 0xff}) || bytes.HasPrefix(src, []byte{0xff, 0xfe})
+}
+
+// format is the byte order mark and the newline convention of the contents of
+// a file. The convention is the newline which ends the first line.
+type format struct {
+	bom  bool
+	crlf bool
+}
+
+func detectFormat(src []byte) format {
+	var i = bytes.IndexByte(src, '\n')
+	return format{
+		bom:  bytes.HasPrefix(src, bom),
+		crlf: i > 0 && src[i-1] == '\r',
+	}
+}
+
+// newline returns the newline of the convention.
+func (f format) newline() []byte {
+	if f.crlf {
+		return []byte("\r\n")
+	}
+	return []byte("\n")
+}
+
+// preserveFormat applies rewrite to src without its byte order mark and with
+// LF newlines, then restores both on the rewritten contents. The lines which
+// didn't follow the newline convention of src are converted to it.
+func preserveFormat(src []byte, rewrite func([]byte) []byte) []byte {
+	var f = detectFormat(src)
+	if f.bom {
+		src = src[len(bom):]
+	}
+	if f.crlf {
+		src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	}
+
+	var out = rewrite(src)
+	if f.crlf {
+		out = bytes.ReplaceAll(out, []byte("\n"), []byte("\r\n"))
+	}
+	if f.bom {
+		out = append(append([]byte(nil), bom...), out...)
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"strings"
+	"testing"
+)
+
+func crlf(s string) string { return strings.ReplaceAll(s, "\n", "\r\n") }
+
+func TestPreservedFormat(t *testing.T) {
+	const (
+		header = "// Licensed to Someone\n"
+		old    = "// Copyright Someone Else\n\n"
+		src    = "package main\n"
+	)
+	var bom = string(bom)
+
+	tests := []struct {
+		name    string
+		rewrite func([]byte) ([]byte, error)
+		src     string
+		want    string
+	}{
+		{
+			name:    "RewriteWithHeader keeps CRLF",
+			rewrite: func(b []byte) ([]byte, error) { return RewriteWithHeader(b, []byte(header)), nil },
+			src:     crlf(old + src),
+			want:    crlf(header + "\n" + src),
+		},
+		{
+			name:    "RewriteWithHeader keeps the BOM",
+			rewrite: func(b []byte) ([]byte, error) { return RewriteWithHeader(b, []byte(header)), nil },
+			src:     bom + old + src,
+			want:    bom + header + "\n" + src,
+		},
+		{
+			name:    "RewriteGoWithHeader keeps the BOM and CRLF",
+			rewrite: func(b []byte) ([]byte, error) { return RewriteGoWithHeader(b, []byte(header)) },
+			src:     bom + crlf(old+src),
+			want:    bom + crlf(header+"\n"+src),
+		},
+		{
+			name:    "PrependHeader converts the lines to the convention of the first one",
+			rewrite: func(b []byte) ([]byte, error) { return PrependHeader(b, []byte(header)), nil },
+			src:     "package main\r\n\nfunc main() {}\n",
+			want:    crlf(header + "\n" + "package main\n\nfunc main() {}\n"),
+		},
+		{
+			name: "UpgradeHeader keeps the BOM and CRLF",
+			rewrite: func(b []byte) ([]byte, error) {
+				return UpgradeHeader(b, []string{"// Copyright Someone Else"}, []byte(header)), nil
+			},
+			src:  bom + crlf(old+src),
+			want: bom + crlf(header+"\n"+src),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rewrite([]byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("rewrite() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainsHeader_format(t *testing.T) {
+	var header = []string{"// Licensed to Someone", "// under the Apache License."}
+	for name, src := range map[string]string{
+		"CRLF":         crlf("// Licensed to Someone\n// under the Apache License.\n\npackage main\n"),
+		"BOM":          string(bom) + "// Licensed to Someone\n// under the Apache License.\n",
+		"BOM and CRLF": string(bom) + crlf("// Licensed to Someone\n// under the Apache License.\n"),
+	} {
+		if !ContainsHeader(strings.NewReader(src), header) {
+			t.Errorf("ContainsHeader() = false with %s", name)
+		}
+	}
+}
+
+func TestIsUTF16(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{src: "\xff\xfe/\x00/\x00", want: true},
+		{src: "\xfe\xff\x00/\x00/", want: true},
+		{src: string(bom) + "// UTF-8"},
+		{src: "// UTF-8"},
+		{src: ""},
+	}
+	for _, tt := range tests {
+		if got := IsUTF16([]byte(tt.src)); got != tt.want {
+			t.Errorf("IsUTF16(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+
+	if _, err := RewriteGoWithHeader([]byte("\xff\xfe/\x00/\x00"), []byte("// h\n")); err != ErrUTF16 {
+		t.Errorf("RewriteGoWithHeader() = %v, want %v", err, ErrUTF16)
+	}
+}
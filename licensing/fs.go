@@ -0,0 +1,163 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WriteFS is a file system whose files can be rewritten, which fixing the
+// files requires. The file systems which only implement fs.FS, like embed.FS
+// or zip.Reader, can only be checked.
+type WriteFS interface {
+	fs.FS
+
+	// WriteFile replaces the file name with the contents written by write,
+	// with the permissions perm. The file is never left partially written,
+	// even when write fails.
+	WriteFile(name string, perm fs.FileMode, write func(w io.Writer) error) error
+}
+
+// DirFS returns a WriteFS for the tree of files rooted at dir. Unlike
+// os.DirFS, the errors hold the path of the files rather than their name.
+func DirFS(dir string) WriteFS {
+	return dirFS(dir)
+}
+
+type dirFS string
+
+func (dir dirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(dir), filepath.FromSlash(name)), nil
+}
+
+func (dir dirFS) Open(name string) (fs.File, error) {
+	path, err := dir.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (dir dirFS) Stat(name string) (fs.FileInfo, error) {
+	path, err := dir.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(path)
+}
+
+// WriteFile writes the contents to a temporary file next to the file, which
+// is then renamed over it. Symbolic links are followed, so that their target
+// is rewritten rather than replaced by a regular file. The files which can't be
+// replaced without losing their hard links or their owner are rewritten in
+// place instead, and read-only files aren't written at all.
+func (dir dirFS) WriteFile(name string, perm fs.FileMode, write func(io.Writer) error) error {
+	path, err := dir.join("write", name)
+	if err != nil {
+		return err
+	}
+
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return replaceFile(path, perm, nil, write)
+	case err != nil:
+		return err
+	case info.Mode().Perm()&0222 == 0:
+		return &fs.PathError{Op: "write", Path: path, Err: fs.ErrPermission}
+	case !info.Mode().IsRegular() || links(info) > 1:
+		return rewriteFile(path, write)
+	}
+	return replaceFile(path, perm, info, write)
+}
+
+// replaceFile writes the file in path through a temporary file renamed over
+// it. When the temporary file can't be given the owner of the existing file
+// described by info, the file is rewritten in place instead.
+func replaceFile(path string, perm fs.FileMode, info fs.FileInfo, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if info != nil {
+		if err := chown(tmp, info); err != nil {
+			tmp.Close()
+			return rewriteFile(path, write)
+		}
+	}
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// rewriteFile truncates and writes the file in path, keeping its identity.
+// The contents are written to memory first so that the file is left as it was
+// when write fails, but unlike replaceFile, an I/O error can leave the file
+// partially written.
+func rewriteFile(path string, write func(io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := buf.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeFS returns fsys as a WriteFS, or an error for the file name when it
+// can't be written to.
+func writeFS(fsys fs.FS, name string) (WriteFS, error) {
+	if w, ok := fsys.(WriteFS); ok {
+		return w, nil
+	}
+	return nil, &fs.PathError{Op: "write", Path: name, Err: errors.ErrUnsupported}
+}
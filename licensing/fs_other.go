@@ -0,0 +1,32 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !unix
+
+package licensing
+
+import (
+	"io/fs"
+	"os"
+)
+
+// links returns the number of hard links to the file, which isn't known on
+// this platform.
+func links(fs.FileInfo) uint64 { return 1 }
+
+// chown is a no-op, files have no owner to keep on this platform.
+func chown(*os.File, fs.FileInfo) error { return nil }
@@ -0,0 +1,137 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDirFS(t *testing.T) {
+	var dir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "main.go"), []byte("package sub\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var fsys = DirFS(dir)
+	if err := fstest.TestFS(fsys, "sub/main.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fsys.WriteFile("sub/main.go", 0600, func(w io.Writer) error {
+		_, err := io.WriteString(w, "package main\n")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := fs.ReadFile(fsys, "sub/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "package main\n" {
+		t.Errorf("WriteFile() wrote %q, want %q", got, "package main\n")
+	}
+	if info, _ := fs.Stat(fsys, "sub/main.go"); info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want %v", info.Mode().Perm(), fs.FileMode(0600))
+	}
+
+	// A failed write leaves the file as it was.
+	var errWrite = errors.New("write failed")
+	if err := fsys.WriteFile("sub/main.go", 0644, func(w io.Writer) error {
+		io.WriteString(w, "package")
+		return errWrite
+	}); err != errWrite {
+		t.Errorf("WriteFile() = %v, want %v", err, errWrite)
+	}
+	if got, _ := fs.ReadFile(fsys, "sub/main.go"); string(got) != "package main\n" {
+		t.Errorf("failed WriteFile() left %q", got)
+	}
+
+	if _, err := fsys.Open("../main.go"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Open() = %v, want %v", err, fs.ErrInvalid)
+	}
+	if _, err := fsys.Open("missing.go"); !errors.Is(err, fs.ErrNotExist) || err.(*fs.PathError).Path != filepath.Join(dir, "missing.go") {
+		t.Errorf("Open() = %v, want the path of the missing file", err)
+	}
+}
+
+func TestRewriteFileFS_readOnly(t *testing.T) {
+	var fsys = fstest.MapFS{"main.go": {Data: []byte("package main\n")}}
+	var err = RewriteFileFS(fsys, "main.go", func(preamble []byte) ([]byte, error) {
+		return append([]byte("// header\n\n"), preamble...), nil
+	})
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("RewriteFileFS() = %v, want %v", err, errors.ErrUnsupported)
+	}
+}
+
+func TestDirFS_WriteFile_links(t *testing.T) {
+	var dir = t.TempDir()
+	var target = filepath.Join(dir, "target.go")
+	if err := os.WriteFile(target, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("target.go", filepath.Join(dir, "symlink.go")); err != nil {
+		t.Skip(err)
+	}
+	if err := os.Link(target, filepath.Join(dir, "hardlink.go")); err != nil {
+		t.Skip(err)
+	}
+
+	var fsys = DirFS(dir)
+	var rewrite = func(name, contents string) error {
+		return fsys.WriteFile(name, 0644, func(w io.Writer) error {
+			_, err := io.WriteString(w, contents)
+			return err
+		})
+	}
+
+	if err := rewrite("symlink.go", "package symlink\n"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(filepath.Join(dir, "symlink.go")); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("symlink replaced by %v (%v)", info.Mode(), err)
+	}
+
+	if err := rewrite("hardlink.go", "package hardlink\n"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"target.go", "symlink.go", "hardlink.go"} {
+		if got, _ := fs.ReadFile(fsys, name); string(got) != "package hardlink\n" {
+			t.Errorf("%s = %q, want the contents written through the links", name, got)
+		}
+	}
+
+	if err := os.Chmod(target, 0444); err != nil {
+		t.Fatal(err)
+	}
+	if err := rewrite("symlink.go", "package readonly\n"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("WriteFile() = %v, want %v", err, fs.ErrPermission)
+	}
+	if got, _ := fs.ReadFile(fsys, "target.go"); string(got) != "package hardlink\n" {
+		t.Errorf("read-only file rewritten to %q", got)
+	}
+}
@@ -0,0 +1,177 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// RewriteSourceWithHeader returns src with the header. The files with the
+// .go extension are rewritten with RewriteGoWithHeader, falling back to
+// RewriteWithHeader when they can't be parsed.
+func RewriteSourceWithHeader(path string, src, header []byte) []byte {
+	if filepath.Ext(path) == ".go" {
+		if data, err := RewriteGoWithHeader(src, header); err == nil {
+			return data
+		}
+	}
+	return RewriteWithHeader(src, header)
+}
+
+// RewriteGoWithHeader is the Go aware version of RewriteWithHeader. It parses
+// the comments above the package clause of src to find the existing license
+// header, which may follow the build constraints, and replaces it with the
+// header at the top of the file. The header is always followed by a blank
+// line, so that it never becomes the package documentation, which is kept
+// along with the build constraints. It fails when src can't be parsed up to
+// its package clause.
+func RewriteGoWithHeader(src, header []byte) ([]byte, error) {
+	if IsUTF16(src) {
+		return nil, ErrUTF16
+	}
+
+	var err error
+	var out = preserveFormat(src, func(src []byte) []byte {
+		var fset = token.NewFileSet()
+		var f *ast.File
+		if f, err = parser.ParseFile(fset, "", src, parser.ParseComments|parser.PackageClauseOnly); err != nil {
+			return nil
+		}
+
+		var rest = src
+		if start, end, ok := goHeader(fset, f, header); ok {
+			// Drop the old header along with the blank lines which follow it.
+			for end < len(src) && src[end] == '\n' {
+				end++
+			}
+			rest = append(append([]byte(nil), src[:start]...), src[end:]...)
+		}
+
+		var out = append([]byte(nil), header...)
+		for !bytes.HasSuffix(out, []byte("\n\n")) {
+			out = append(out, '\n')
+		}
+		return append(out, bytes.TrimLeft(rest, "\n")...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HeaderIsPackageDoc reports whether the license header at the top of src is
+// attached to the package clause, which makes it the package documentation.
+func HeaderIsPackageDoc(src []byte) (bool, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments|parser.PackageClauseOnly)
+	if err != nil {
+		return false, err
+	}
+	return f.Doc != nil && f.Comments[0] == f.Doc && isLicenseComment(f.Doc.List[0]), nil
+}
+
+// goHeader returns the offsets in the file of the license header found above
+// the package clause of f. Like headerBytes, the header spans the comments
+// from the first one which starts a license up to the ones which start the
+// build constraints, the generated code banner or the package documentation.
+// When the header starts with the lines of the expected header, only these
+// lines are part of it.
+func goHeader(fset *token.FileSet, f *ast.File, header []byte) (start, end int, ok bool) {
+	var expected = strings.Split(strings.TrimRight(string(header), "\n"), "\n")
+	var offset = func(p token.Pos) int { return fset.Position(p).Offset }
+
+	for _, g := range f.Comments {
+		if g.Pos() >= f.Package {
+			break
+		}
+		if !ok {
+			if isConstraints(g) {
+				continue
+			}
+			if !isLicenseComment(g.List[0]) {
+				return 0, 0, false
+			}
+			if len(g.List) >= len(expected) && commentsEqual(g.List[:len(expected)], expected) {
+				return offset(g.Pos()), offset(g.List[len(expected)-1].End()), true
+			}
+			start, ok = offset(g.Pos()), true
+		} else if g == f.Doc {
+			break
+		}
+
+		for i, c := range g.List {
+			// A block comment can only be the whole license header.
+			var block = strings.HasPrefix(c.Text, "/*")
+			if isHeaderEnd(c) || (block && (end > 0 || i > 0)) {
+				return start, end, true
+			}
+			end = offset(c.End())
+			if block {
+				return start, end, true
+			}
+		}
+	}
+
+	return start, end, ok
+}
+
+func isLicenseComment(c *ast.Comment) bool {
+	var text = c.Text
+	if strings.HasPrefix(text, "/*") {
+		text = "// " + strings.TrimLeft(text[2:], " \t\r\n*")
+	}
+	for _, p := range startPrefixes {
+		if strings.HasPrefix(text, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHeaderEnd(c *ast.Comment) bool {
+	for _, p := range endPrefixes {
+		if strings.HasPrefix(c.Text, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConstraints reports whether the comment group only holds build
+// constraints or other //go: directives.
+func isConstraints(g *ast.CommentGroup) bool {
+	for _, c := range g.List {
+		if !strings.HasPrefix(c.Text, "//go:") && !strings.HasPrefix(c.Text, "// +build ") {
+			return false
+		}
+	}
+	return true
+}
+
+func commentsEqual(comments []*ast.Comment, lines []string) bool {
+	for i, c := range comments {
+		if strings.TrimRight(c.Text, "\r") != lines[i] {
+			return false
+		}
+	}
+	return true
+}
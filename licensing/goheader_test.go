@@ -0,0 +1,128 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"testing"
+)
+
+const (
+	testGoHeader = "// Licensed to Someone\n// under the Apache License.\n"
+	testOldGo    = "// Copyright Someone Else\n// All rights reserved.\n"
+)
+
+func TestRewriteGoWithHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "No header",
+			src:  "package main\n",
+			want: testGoHeader + "\npackage main\n",
+		},
+		{
+			name: "Header attached to the package clause",
+			src:  testGoHeader + "package main\n",
+			want: testGoHeader + "\npackage main\n",
+		},
+		{
+			name: "Header followed by the package documentation",
+			src:  testGoHeader + "// main does things.\npackage main\n",
+			want: testGoHeader + "\n// main does things.\npackage main\n",
+		},
+		{
+			name: "Old header followed by the package documentation",
+			src:  testOldGo + "// Package main does things.\npackage main\n",
+			want: testGoHeader + "\n// Package main does things.\npackage main\n",
+		},
+		{
+			name: "Old header followed by a block documentation",
+			src:  testOldGo + "/*\nMain does things.\n*/\npackage main\n",
+			want: testGoHeader + "\n/*\nMain does things.\n*/\npackage main\n",
+		},
+		{
+			name: "Old header in a block comment",
+			src:  "/*\n * Copyright Someone Else\n */\n\n// Package main does things.\npackage main\n",
+			want: testGoHeader + "\n// Package main does things.\npackage main\n",
+		},
+		{
+			name: "Old header split by blank lines",
+			src:  testOldGo + "\n// More terms.\n\n//go:build linux\n\npackage main\n",
+			want: testGoHeader + "\n//go:build linux\n\npackage main\n",
+		},
+		{
+			name: "Old header after the build constraints",
+			src:  "//go:build linux\n// +build linux\n\n" + testOldGo + "\n// Package main does things.\npackage main\n",
+			want: testGoHeader + "\n//go:build linux\n// +build linux\n\n// Package main does things.\npackage main\n",
+		},
+		{
+			name: "Old separated header and documentation",
+			src:  testOldGo + "\n// main does things.\npackage main\n",
+			want: testGoHeader + "\n// main does things.\npackage main\n",
+		},
+		{
+			name: "Generated code banner is kept",
+			src:  "// Code generated by a tool. DO NOT EDIT.\n\npackage main\n",
+			want: testGoHeader + "\n// Code generated by a tool. DO NOT EDIT.\n\npackage main\n",
+		},
+		{
+			name:    "Invalid Go",
+			src:     "<html></html>\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RewriteGoWithHeader([]byte(tt.src), []byte(testGoHeader))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RewriteGoWithHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("RewriteGoWithHeader() = \n%s\n want \n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderIsPackageDoc(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{name: "Separated header", src: testGoHeader + "\npackage main\n"},
+		{name: "Separated documentation", src: testGoHeader + "\n// Package main does things.\npackage main\n"},
+		{name: "No header", src: "// Package main does things.\npackage main\n"},
+		{name: "Attached header", src: testGoHeader + "package main\n", want: true},
+		{name: "Attached header and documentation", src: testGoHeader + "// Package main does things.\npackage main\n", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HeaderIsPackageDoc([]byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("HeaderIsPackageDoc() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
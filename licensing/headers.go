@@ -17,8 +17,9 @@
 
 package licensing
 
-// Headers is the map of supported licenses
-var Headers = map[string][]string{
+// templates are the headers of the supported licenses, the %s verbs in their
+// lines are replaced by the licensor.
+var templates = map[string][]string{
 	"ASL2": {
 		`// Licensed to %s under one or more contributor`,
 		`// license agreements. See the NOTICE file distributed with`,
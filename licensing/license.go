@@ -22,7 +22,6 @@ import (
 	"bytes"
 	"errors"
 	"io"
-	"os"
 	"reflect"
 	"strings"
 	"sync"
@@ -34,92 +33,90 @@ var (
 
 	errHeaderIsTooShort = errors.New("header is too short")
 
-	defaulBufSize int
-	bufPool       = sync.Pool{
+	readerPool = sync.Pool{
 		New: func() interface{} {
-			buf := make([]byte, defaulBufSize)
-			return buf
+			return bufio.NewReaderSize(nil, readerSize)
 		},
 	}
 )
 
-func init() {
-	// Iterate over the supported licenses to make sure everything fit
-	// without any additional allocation.
-	for _, v := range Headers {
-		var l int
-		for _, v2 := range v {
-			l += len(v2)
-		}
-
-		if l > defaulBufSize {
-			defaulBufSize = l
-		}
-	}
-}
+// readerSize is the size of the buffer of the readers used by ContainsHeader,
+// which fits the lines of the supported headers.
+const readerSize = 4 << 10
 
 // ContainsHeader reads the first N lines of a file and checks if the header
-// matches the one that is expected
+// matches the one that is expected. The lines of the file can be of any
+// length, the ones longer than the header lines aren't read in full.
 func ContainsHeader(r io.Reader, headerLines []string) bool {
-	var scanner = bufio.NewScanner(r)
-	var i int
-
-	buf := bufPool.Get().([]byte)
-	defer bufPool.Put(buf)
-	scanner.Buffer(buf, defaulBufSize)
+	var br *bufio.Reader
+	var size = readerSize
+	for _, line := range headerLines {
+		// The line may end with \r\n.
+		if len(line)+2 > size {
+			size = len(line) + 2
+		}
+	}
+	if size == readerSize {
+		br = readerPool.Get().(*bufio.Reader)
+		br.Reset(r)
+		defer func() {
+			br.Reset(nil)
+			readerPool.Put(br)
+		}()
+	} else {
+		br = bufio.NewReaderSize(r, size)
+	}
 
-	for i = 0; scanner.Scan(); i++ {
-		line := scanner.Bytes()
+	for i, want := range headerLines {
+		// A line which fills the buffer is longer than the header line.
+		line, err := br.ReadSlice('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return false
+		}
 
-		// end of license, break out of the loop
-		if i == len(headerLines) {
-			break
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+		if i == 0 {
+			line = bytes.TrimPrefix(line, bom)
 		}
 
 		// compare line by line without storing the whole file
 		// in memory
-		if !bytes.Equal(line, []byte(headerLines[i])) {
+		if !bytes.Equal(line, []byte(want)) {
 			return false
 		}
 	}
 
-	// file is shorter than license
-	if i < len(headerLines) {
-		return false
-	}
-
 	return true
 }
 
-// RewriteFileWithHeader reads a file from a path and rewrites it with a header
+// RewriteFileWithHeader reads a file from a path and rewrites it with a header,
+// which is placed with RewriteSourceWithHeader. Only the preamble of the file
+// is read in memory, see RewriteFile.
 func RewriteFileWithHeader(path string, header []byte) error {
 	if len(header) < 2 {
 		return errHeaderIsTooShort
 	}
 
-	info, err := os.Stat(path)
-	if err != nil {
-		return err
-	}
-
-	origin, err := os.ReadFile(path)
-	if err != nil {
-		return err
-	}
-
-	data := RewriteWithHeader(origin, header)
-	return os.WriteFile(path, data, info.Mode())
+	return RewriteFile(path, func(preamble []byte) ([]byte, error) {
+		if IsUTF16(preamble) {
+			return nil, ErrUTF16
+		}
+		return RewriteSourceWithHeader(path, preamble, header), nil
+	})
 }
 
 // RewriteWithHeader rewrites the src byte buffers header with the new header.
+// The byte order mark and the newline convention of src are preserved.
 func RewriteWithHeader(src []byte, header []byte) []byte {
-	// Ensures that the header includes two break lines as the last bytes
-	for !reflect.DeepEqual(header[len(header)-2:], []byte("\n\n")) {
-		header = append(header, []byte("\n")...)
-	}
+	return preserveFormat(src, func(src []byte) []byte {
+		// Ensures that the header includes two break lines as the last bytes
+		for !reflect.DeepEqual(header[len(header)-2:], []byte("\n\n")) {
+			header = append(header, []byte("\n")...)
+		}
 
-	var oldHeader = headerBytes(bytes.NewReader(src))
-	return bytes.Replace(src, oldHeader, header, 1)
+		var oldHeader = headerBytes(bytes.NewReader(src))
+		return bytes.Replace(src, oldHeader, header, 1)
+	})
 }
 
 // headerBytes detects the header lines of an io.Reader contents and returns
@@ -151,6 +148,66 @@ func headerBytes(r io.Reader) []byte {
 	return replaceableHeader
 }
 
+// FindHeader looks for the header lines in full within the first maxLines
+// lines read from r. It returns the index of the line the header starts at,
+// which is 0 when the header is in place.
+func FindHeader(r io.Reader, headerLines []string, maxLines int) (int, bool) {
+	if len(headerLines) == 0 {
+		return 0, false
+	}
+
+	var head bytes.Buffer
+	var lines []string
+	var scanner = bufio.NewScanner(r)
+	for len(lines) < maxLines && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		head.WriteString(scanner.Text() + "\n")
+	}
+
+	// Most files don't hold any of the header lines, which is cheaper to
+	// rule out than the header itself.
+	if !containsHeaderLine(&head, headerLines) {
+		return 0, false
+	}
+
+	for i := 0; i+len(headerLines) <= len(lines); i++ {
+		if reflect.DeepEqual(lines[i:i+len(headerLines)], headerLines) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// MoveHeader returns src with the n lines starting at the line index, which
+// hold the header, moved to the top of the file. The blank lines which
+// followed the header are dropped.
+func MoveHeader(src []byte, line, n int) []byte {
+	return preserveFormat(src, func(src []byte) []byte {
+		var start, end = lineOffset(src, line), lineOffset(src, line+n)
+		var header = append([]byte(nil), src[start:end]...)
+		for end < len(src) && src[end] == '\n' {
+			end++
+		}
+
+		var rest = append(append([]byte(nil), src[:start]...), src[end:]...)
+		return PrependHeader(bytes.TrimLeft(rest, "\n"), header)
+	})
+}
+
+// lineOffset returns the offset at which the line index starts in src, or
+// the length of src when it has fewer lines.
+func lineOffset(src []byte, line int) int {
+	var offset int
+	for ; line > 0; line-- {
+		var i = bytes.IndexByte(src[offset:], '\n')
+		if i < 0 {
+			return len(src)
+		}
+		offset += i + 1
+	}
+	return offset
+}
+
 // containsHeaderLine reads the first N lines of a file and checks if the header
 // matches the one that is expected
 func containsHeaderLine(r io.Reader, headerLines []string) bool {
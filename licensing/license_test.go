@@ -455,6 +455,67 @@ func TestContainsHeaderLine(t *testing.T) {
 	}
 }
 
+func TestFindHeader(t *testing.T) {
+	var header = []string{"// Licensed to Someone", "// under the Apache License."}
+	tests := []struct {
+		name     string
+		src      string
+		maxLines int
+		want     int
+		wantOK   bool
+	}{
+		{name: "Header in place", src: "// Licensed to Someone\n// under the Apache License.\n\npackage main\n", maxLines: 10, want: 0, wantOK: true},
+		{name: "Header after the package clause", src: "package main\n\n// Licensed to Someone\n// under the Apache License.\n", maxLines: 10, want: 2, wantOK: true},
+		{name: "Header past the maximum lines", src: "package main\n\n// Licensed to Someone\n// under the Apache License.\n", maxLines: 3},
+		{name: "Partial header", src: "package main\n\n// Licensed to Someone\n", maxLines: 10},
+		{name: "No header", src: "package main\n", maxLines: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := FindHeader(strings.NewReader(tt.src), header, tt.maxLines)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("FindHeader() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMoveHeader(t *testing.T) {
+	var header = "// Licensed to Someone\n// under the Apache License.\n"
+	tests := []struct {
+		name string
+		src  string
+		line int
+		want string
+	}{
+		{
+			name: "Header after the build tags",
+			src:  "//go:build linux\n\n" + header + "\npackage main\n",
+			line: 2,
+			want: header + "\n//go:build linux\n\npackage main\n",
+		},
+		{
+			name: "Header after the package clause",
+			src:  "package main\n\n" + header + "\nimport \"os\"\n",
+			line: 2,
+			want: header + "\npackage main\n\nimport \"os\"\n",
+		},
+		{
+			name: "Header at the end of the file",
+			src:  "package main\n\n" + strings.TrimSuffix(header, "\n"),
+			line: 2,
+			want: header + "\npackage main\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MoveHeader([]byte(tt.src), tt.line, 2); string(got) != tt.want {
+				t.Errorf("MoveHeader() = \n%s\n want \n%s", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_headerBytes(t *testing.T) {
 	var singleHeader = `
 // Copyright 2017 The elastic/go-licenser Authors. All rights reserved.
@@ -0,0 +1,199 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLicensor is the licensor which the Headers are rendered with.
+const DefaultLicensor = "Elasticsearch B.V."
+
+var (
+	// Default is the registry of the supported licenses.
+	Default = newDefaultRegistry()
+
+	// Headers maps the supported licenses to their header rendered with the
+	// DefaultLicensor. It is a read-only view of Default which is kept for
+	// compatibility, modifying it has no effect.
+	//
+	// Deprecated: use Default.Render, which renders any licensor.
+	Headers = Default.renderAll(Params{Licensor: DefaultLicensor})
+)
+
+// Params are the values which are rendered into a license header.
+type Params struct {
+	// Licensor replaces the %s verbs in the lines of the header.
+	Licensor string
+}
+
+// Registry holds the header templates of a set of licenses, along with the
+// former wordings of their headers. It is safe for concurrent use. The
+// templates and variants can't be modified once registered, and the lines
+// returned by the registry are copies which the callers are free to modify.
+type Registry struct {
+	mu       sync.RWMutex
+	headers  map[string][]string
+	variants map[string][]Variant
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{headers: make(map[string][]string), variants: make(map[string][]Variant)}
+}
+
+// Clone returns a copy of the registry, whose licenses and variants can be
+// added to without modifying r.
+func (r *Registry) Clone() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var c = NewRegistry()
+	for k, lines := range r.headers {
+		c.headers[k] = append([]string(nil), lines...)
+	}
+	for k, vs := range r.variants {
+		c.variants[k] = append([]Variant(nil), vs...)
+	}
+	return c
+}
+
+func newDefaultRegistry() *Registry {
+	var r = NewRegistry()
+	for k, lines := range templates {
+		if err := r.Register(k, lines); err != nil {
+			panic(err)
+		}
+	}
+	for k, vs := range variants {
+		for _, v := range vs {
+			if err := r.RegisterVariant(k, v); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return r
+}
+
+// Register adds the header template of the license key. The %s verbs in its
+// lines are replaced by the licensor when rendered. It fails when the key is
+// already registered or the template is empty.
+func (r *Registry) Register(key string, lines []string) error {
+	if key == "" || len(lines) == 0 {
+		return fmt.Errorf("empty header template for license %q", key)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.headers[key]; ok {
+		return fmt.Errorf("license %s is already registered", key)
+	}
+	r.headers[key] = append([]string(nil), lines...)
+	return nil
+}
+
+// RegisterVariant adds a former wording of the header of the license key,
+// which must be registered. The variants of a license are registered from the
+// most recent to the oldest. It fails when the variant has no name or lines,
+// or when the license already has a variant of the same name.
+func (r *Registry) RegisterVariant(key string, v Variant) error {
+	if v.Name == "" || len(v.Lines) == 0 {
+		return fmt.Errorf("incomplete variant %q of license %s", v.Name, key)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.headers[key]; !ok {
+		return fmt.Errorf("variant %s of unknown license %s", v.Name, key)
+	}
+	for _, other := range r.variants[key] {
+		if other.Name == v.Name {
+			return fmt.Errorf("variant %s of license %s is already registered", v.Name, key)
+		}
+	}
+	r.variants[key] = append(r.variants[key], Variant{Name: v.Name, Lines: append([]string(nil), v.Lines...)})
+	return nil
+}
+
+// Variants returns copies of the variants of the license key, from the most
+// recent to the oldest.
+func (r *Registry) Variants(key string) []Variant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var vs = make([]Variant, 0, len(r.variants[key]))
+	for _, v := range r.variants[key] {
+		vs = append(vs, Variant{Name: v.Name, Lines: append([]string(nil), v.Lines...)})
+	}
+	return vs
+}
+
+// Lookup returns a copy of the header template of the license key.
+func (r *Registry) Lookup(key string) ([]string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	lines, ok := r.headers[key]
+	if !ok {
+		return nil, false
+	}
+	return append([]string(nil), lines...), true
+}
+
+// Keys returns the sorted keys of the registered licenses.
+func (r *Registry) Keys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var keys = make([]string, 0, len(r.headers))
+	for k := range r.headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Render returns the header lines of the license key with the params.
+func (r *Registry) Render(key string, p Params) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	lines, ok := r.headers[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown license: %s", key)
+	}
+	return render(lines, p), nil
+}
+
+// render returns a copy of the template lines with the params.
+func render(lines []string, p Params) []string {
+	var rendered = make([]string, len(lines))
+	for i, line := range lines {
+		if strings.Contains(line, "%s") {
+			line = fmt.Sprintf(line, p.Licensor)
+		}
+		rendered[i] = line
+	}
+	return rendered
+}
+
+func (r *Registry) renderAll(p Params) map[string][]string {
+	var headers = make(map[string][]string)
+	for _, k := range r.Keys() {
+		headers[k], _ = r.Render(k, p)
+	}
+	return headers
+}
@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegistry_Register(t *testing.T) {
+	var r = NewRegistry()
+	if err := r.Register("Test", []string{"// Copyright %s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		key   string
+		lines []string
+	}{
+		{name: "Already registered", key: "Test", lines: []string{"// other"}},
+		{name: "Empty key", lines: []string{"// other"}},
+		{name: "Empty template", key: "Other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.Register(tt.key, tt.lines); err == nil {
+				t.Errorf("Register(%q) succeeded, want an error", tt.key)
+			}
+		})
+	}
+
+	if got, _ := r.Lookup("Test"); !reflect.DeepEqual(got, []string{"// Copyright %s"}) {
+		t.Errorf("Lookup() = %v, the template changed", got)
+	}
+}
+
+func TestRegistry_Render(t *testing.T) {
+	var template = []string{"// Copyright %s", "// All rights reserved."}
+	var r = NewRegistry()
+	if err := r.Register("Test", template); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither the registered lines nor the returned ones are shared.
+	template[1] = "// modified"
+	lines, _ := r.Lookup("Test")
+	lines[0] = "// modified"
+
+	for _, licensor := range []string{"Someone", "Someone Else"} {
+		got, err := r.Render("Test", Params{Licensor: licensor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want = []string{"// Copyright " + licensor, "// All rights reserved."}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Render(%s) = %v, want %v", licensor, got, want)
+		}
+	}
+
+	if _, err := r.Render("Missing", Params{}); err == nil {
+		t.Error("Render() of an unknown license succeeded")
+	}
+}
+
+func TestRegistry_concurrent(t *testing.T) {
+	var r = NewRegistry()
+	var wg sync.WaitGroup
+	for i, licensor := range []string{"A", "B", "C", "D"} {
+		var key = string(rune('W' + i))
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := r.Register(key, []string{"// Licensed to %s"}); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for _, k := range r.Keys() {
+				if lines, err := r.Render(k, Params{Licensor: licensor}); err != nil || lines[0] != "// Licensed to "+licensor {
+					t.Errorf("Render(%s, %s) = %v, %v", k, licensor, lines, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := r.Keys(); !reflect.DeepEqual(got, []string{"W", "X", "Y", "Z"}) {
+		t.Errorf("Keys() = %v", got)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	// The compatibility view holds the rendered headers, which match the files
+	// licensed by the DefaultLicensor.
+	for _, k := range Default.Keys() {
+		var src = strings.Join(Headers[k], "\n") + "\n\npackage main\n"
+		if strings.Contains(src, "%s") {
+			t.Errorf("Headers[%s] isn't rendered", k)
+		}
+		if !ContainsHeader(bytes.NewReader([]byte(src)), Headers[k]) {
+			t.Errorf("ContainsHeader() doesn't match Headers[%s]", k)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// PreambleSize is the maximum size of the preamble of a file, the part at its
+// top which holds the header and which is checked and rewritten in memory.
+const PreambleSize = 64 << 10
+
+// ReadPreamble reads the preamble of r: up to PreambleSize bytes, ending at
+// the end of a line unless its last line is longer than the preamble.
+func ReadPreamble(r io.Reader) ([]byte, error) {
+	preamble, _, err := readPreamble(r)
+	return preamble, err
+}
+
+// readPreamble returns the preamble of r along with the bytes which were read
+// past it.
+func readPreamble(r io.Reader) (preamble, rest []byte, err error) {
+	var buf = make([]byte, PreambleSize)
+	n, err := io.ReadFull(r, buf)
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		return buf[:n], nil, nil
+	case nil:
+	default:
+		return nil, nil, err
+	}
+
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		return buf[:i+1], buf[i+1:], nil
+	}
+	return buf, nil, nil
+}
+
+// RewriteFile replaces the preamble of the file found in path, as returned by
+// ReadPreamble, with the one returned by rewrite. The rest of the file is
+// copied as is, so that files of any size are rewritten without reading them
+// in memory. The file is replaced atomically and keeps its mode.
+func RewriteFile(path string, rewrite func(preamble []byte) ([]byte, error)) error {
+	return RewriteFileFS(DirFS(filepath.Dir(path)), filepath.Base(path), rewrite)
+}
+
+// RewriteFileFS is like RewriteFile for the file name of fsys, which must be
+// a WriteFS.
+func RewriteFileFS(fsys fs.FS, name string, rewrite func(preamble []byte) ([]byte, error)) error {
+	wfs, err := writeFS(fsys, name)
+	if err != nil {
+		return err
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	preamble, rest, err := readPreamble(f)
+	if err != nil {
+		return err
+	}
+	rewritten, err := rewrite(preamble)
+	if err != nil {
+		return err
+	}
+
+	return wfs.WriteFile(name, info.Mode().Perm(), func(w io.Writer) error {
+		if _, err := w.Write(rewritten); err != nil {
+			return err
+		}
+		if _, err := w.Write(rest); err != nil {
+			return err
+		}
+		_, err := io.Copy(w, f)
+		return err
+	})
+}
+
+// IsBinary reports whether src, usually the preamble of a file, looks like
+// binary contents rather than text: when it holds a NUL byte or isn't valid
+// UTF-8. It returns the reason when it does.
+func IsBinary(src []byte) (string, bool) {
+	if bytes.IndexByte(src, 0) >= 0 {
+		return "it contains NUL bytes", true
+	}
+
+	// The preamble may end in the middle of a character.
+	var end = len(src)
+	for i := len(src) - 1; i >= 0 && i >= len(src)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(src[i]) {
+			if !utf8.FullRune(src[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	if !utf8.Valid(src[:end]) {
+		return "it isn't valid UTF-8", true
+	}
+	return "", false
+}
@@ -0,0 +1,200 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadPreamble(t *testing.T) {
+	var long = strings.Repeat("a", PreambleSize)
+	tests := []struct {
+		name     string
+		src      string
+		want     string
+		wantRest string
+	}{
+		{name: "Short file", src: "// header\npackage main\n", want: "// header\npackage main\n"},
+		{name: "Preamble ends at a line", src: "// header\n" + long, want: "// header\n", wantRest: long[:PreambleSize-len("// header\n")]},
+		{name: "Single long line", src: long + "\n", want: long},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := readPreamble(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want || string(rest) != tt.wantRest {
+				t.Errorf("readPreamble() = %d, %d bytes, want %d, %d", len(got), len(rest), len(tt.want), len(tt.wantRest))
+			}
+		})
+	}
+}
+
+func TestRewriteFile(t *testing.T) {
+	// The file is larger than the preamble and has lines longer than it.
+	var body = "package main\n\nvar data = \"" + strings.Repeat("x", 3*PreambleSize) + "\"\n"
+	var path = filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RewriteFileWithHeader(path, []byte("// Licensed to Someone\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "// Licensed to Someone\n\n" + body; string(got) != want {
+		t.Errorf("RewriteFileWithHeader() = %d bytes starting with %q, want %d bytes", len(got), got[:30], len(want))
+	}
+	if !ContainsHeader(bytes.NewReader(got), []string{"// Licensed to Someone"}) {
+		t.Error("ContainsHeader() = false after the rewrite")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want %v", info.Mode().Perm(), os.FileMode(0755))
+	}
+}
+
+func TestContainsHeader_longLines(t *testing.T) {
+	var long = strings.Repeat("x", 1<<20)
+	tests := []struct {
+		name   string
+		src    string
+		header []string
+		want   bool
+	}{
+		{name: "Long line after the header", src: "// a\n// b\n" + long + "\n", header: []string{"// a", "// b"}, want: true},
+		{name: "Long first line", src: long + "\n// a\n", header: []string{"// a"}},
+		{name: "Long header line", src: "// " + long + "\npackage main\n", header: []string{"// " + long}, want: true},
+		{name: "Header line is a prefix of a long line", src: "// a" + long + "\n", header: []string{"// a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContainsHeader(strings.NewReader(tt.src), tt.header); got != tt.want {
+				t.Errorf("ContainsHeader() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "Text", src: "// héllo\npackage main\n"},
+		{name: "Truncated character", src: "// h\xc3"},
+		{name: "NUL bytes", src: "\x89PNG\r\n\x1a\n\x00\x00", want: "it contains NUL bytes"},
+		{name: "Invalid UTF-8", src: "// h\xe9llo\n", want: "it isn't valid UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, binary := IsBinary([]byte(tt.src))
+			if got != tt.want || binary != (tt.want != "") {
+				t.Errorf("IsBinary() = %q, %v, want %q", got, binary, tt.want)
+			}
+		})
+	}
+}
+
+// repeatReader returns size bytes of line, repeated, without allocating them.
+type repeatReader struct {
+	line []byte
+	size int64
+	read int64
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.read >= r.size {
+		return 0, io.EOF
+	}
+	var n int
+	for n < len(p) && r.read < r.size {
+		var c = copy(p[n:], r.line[r.read%int64(len(r.line)):])
+		if rem := r.size - r.read; int64(c) > rem {
+			c = int(rem)
+		}
+		n += c
+		r.read += int64(c)
+	}
+	return n, nil
+}
+
+var benchmarkSizes = []int64{1 << 20, 256 << 20}
+
+func BenchmarkContainsHeader(b *testing.B) {
+	var header, _ = Default.Render("ASL2", Params{Licensor: DefaultLicensor})
+	var text = strings.Join(header, "\n") + "\n\n"
+	for _, size := range benchmarkSizes {
+		for _, line := range []string{"var x = 1\n", strings.Repeat("x", 1<<20)} {
+			b.Run(fmt.Sprintf("%dMiB/line=%d", size>>20, len(line)), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					var r = io.MultiReader(strings.NewReader(text), &repeatReader{line: []byte(line), size: size})
+					if !ContainsHeader(r, header) {
+						b.Fatal("ContainsHeader() = false")
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkRewriteFileWithHeader(b *testing.B) {
+	var header = []byte("// Licensed to Someone\n")
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("%dMiB", size>>20), func(b *testing.B) {
+			var path = filepath.Join(b.TempDir(), "large.go")
+			f, err := os.Create(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var body = &repeatReader{line: []byte("var x = \"" + strings.Repeat("x", 100) + "\"\n"), size: size}
+			if _, err := io.Copy(f, io.MultiReader(strings.NewReader("package main\n\n"), body)); err != nil {
+				b.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetBytes(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := RewriteFileWithHeader(path, header); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Variant is a former wording of the header of a license. Like in the header
+// templates, the %s verbs in its lines are replaced by the licensor.
+type Variant struct {
+	// Name identifies the variant in the reports, e.g. "2019".
+	Name  string   `json:"name"`
+	Lines []string `json:"lines"`
+}
+
+// variants maps the licenses of the Default registry to the former wordings
+// of their header, from the most recent to the oldest. None of the headers
+// has been reworded yet, so the list is empty on purpose: when the wording of
+// a header changes, the previous one is added here so that the files still
+// carrying it are reported as outdated and upgraded rather than reported as
+// missing it. The former wordings of other headers can be registered with
+// LoadVariants.
+var variants = map[string][]Variant{}
+
+// LoadVariants registers the variants read from the JSON document rd, which
+// maps the license keys to their variants, from the most recent to the
+// oldest:
+//
+//	{"ASL2": [{"name": "2019", "lines": ["// Licensed to %s under the Apache License 2.0."]}]}
+func (r *Registry) LoadVariants(rd io.Reader) error {
+	var variants map[string][]Variant
+	var dec = json.NewDecoder(rd)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&variants); err != nil {
+		return fmt.Errorf("invalid variants: %w", err)
+	}
+
+	var keys = make([]string, 0, len(variants))
+	for k := range variants {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range variants[k] {
+			if err := r.RegisterVariant(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Render returns the lines of the variant with the licensor.
+func (v Variant) Render(licensor string) []string {
+	return render(v.Lines, Params{Licensor: licensor})
+}
+
+// FindVariant returns the variant of the license header which the contents
+// of rs start with. The reader is rewound before checking each variant.
+func (r *Registry) FindVariant(rs io.ReadSeeker, license, licensor string) (Variant, bool) {
+	for _, v := range r.Variants(license) {
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return Variant{}, false
+		}
+		if ContainsHeader(rs, v.Render(licensor)) {
+			return v, true
+		}
+	}
+
+	return Variant{}, false
+}
+
+// UpgradeHeader replaces the oldLines found at the top of src with the header,
+// leaving the rest of the contents untouched.
+func UpgradeHeader(src []byte, oldLines []string, header []byte) []byte {
+	return preserveFormat(src, func(src []byte) []byte {
+		var rest = src[lineOffset(src, len(oldLines)):]
+
+		var out = append([]byte(nil), header...)
+		if !bytes.HasSuffix(out, []byte("\n")) {
+			out = append(out, '\n')
+		}
+		return append(out, rest...)
+	})
+}
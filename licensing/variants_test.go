@@ -0,0 +1,173 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package licensing
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVariants(t *testing.T) {
+	for license, vs := range variants {
+		if got := Default.Variants(license); len(got) != len(vs) {
+			t.Errorf("license %s has %d variants registered, want %d", license, len(got), len(vs))
+		}
+	}
+}
+
+func TestRegistry_RegisterVariant(t *testing.T) {
+	var r = NewRegistry()
+	if err := r.Register("Test", []string{"// Licensed to %s."}); err != nil {
+		t.Fatal(err)
+	}
+	var lines = []string{"// Licensed to %s, 2019."}
+	if err := r.RegisterVariant("Test", Variant{Name: "2019", Lines: lines}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		license string
+		variant Variant
+	}{
+		{name: "Unknown license", license: "Other", variant: Variant{Name: "2018", Lines: lines}},
+		{name: "Already registered", license: "Test", variant: Variant{Name: "2019", Lines: []string{"// Other"}}},
+		{name: "Missing name", license: "Test", variant: Variant{Lines: lines}},
+		{name: "Missing lines", license: "Test", variant: Variant{Name: "2018"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.RegisterVariant(tt.license, tt.variant); err == nil {
+				t.Errorf("RegisterVariant(%s, %s) succeeded, want an error", tt.license, tt.variant.Name)
+			}
+		})
+	}
+
+	// Neither the registered lines nor the returned ones are shared.
+	lines[0] = "// modified"
+	r.Variants("Test")[0].Lines[0] = "// modified"
+	var want = []Variant{{Name: "2019", Lines: []string{"// Licensed to %s, 2019."}}}
+	if got := r.Variants("Test"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Variants() = %v, want %v", got, want)
+	}
+}
+
+func TestRegistry_LoadVariants(t *testing.T) {
+	var r = NewRegistry()
+	if err := r.Register("Test", []string{"// Licensed to %s."}); err != nil {
+		t.Fatal(err)
+	}
+	var c = r.Clone()
+
+	var doc = `{"Test": [{"name": "2020", "lines": ["// Licensed to %s, 2020."]}, {"name": "2019", "lines": ["// Licensed to %s, 2019."]}]}`
+	if err := c.LoadVariants(strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+	var want = []Variant{
+		{Name: "2020", Lines: []string{"// Licensed to %s, 2020."}},
+		{Name: "2019", Lines: []string{"// Licensed to %s, 2019."}},
+	}
+	if got := c.Variants("Test"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Variants() = %v, want %v", got, want)
+	}
+	if got := r.Variants("Test"); len(got) != 0 {
+		t.Errorf("Variants() of the cloned registry = %v, want none", got)
+	}
+
+	for _, doc := range []string{
+		`{"Other": [{"name": "2019", "lines": ["// Other"]}]}`,
+		`{"Test": [{"name": "2019"}]}`,
+		`{"Test": [{"name": "2018", "text": "// Licensed"}]}`,
+		`[]`,
+	} {
+		if err := r.Clone().LoadVariants(strings.NewReader(doc)); err == nil {
+			t.Errorf("LoadVariants(%s) succeeded, want an error", doc)
+		}
+	}
+}
+
+func TestRegistry_FindVariant(t *testing.T) {
+	var r = NewRegistry()
+	for _, k := range []string{"Test", "ASL2"} {
+		if err := r.Register(k, []string{"// Licensed to %s under the " + k + " license."}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range []Variant{
+		{Name: "2020", Lines: []string{"// Licensed to %s.", "// Some license."}},
+		{Name: "2019", Lines: []string{"// Licensed to %s."}},
+	} {
+		if err := r.RegisterVariant("Test", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		src      string
+		license  string
+		want     string
+		wantFind bool
+	}{
+		{name: "Most recent variant", src: "// Licensed to Acme.\n// Some license.\n\npackage a\n", license: "Test", want: "2020", wantFind: true},
+		{name: "Oldest variant", src: "// Licensed to Acme.\n\npackage a\n", license: "Test", want: "2019", wantFind: true},
+		{name: "Other licensor", src: "// Licensed to Other.\n\npackage a\n", license: "Test"},
+		{name: "License without variants", src: "// Licensed to Acme.\n", license: "ASL2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := r.FindVariant(strings.NewReader(tt.src), tt.license, "Acme")
+			if ok != tt.wantFind || got.Name != tt.want {
+				t.Errorf("FindVariant() = %q, %v, want %q, %v", got.Name, ok, tt.want, tt.wantFind)
+			}
+		})
+	}
+}
+
+func TestUpgradeHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		oldLines []string
+		header   string
+		want     string
+	}{
+		{
+			name:     "Header is replaced in place",
+			src:      "// Old 1\n// Old 2\n\n//go:build linux\n\npackage a\n",
+			oldLines: []string{"// Old 1", "// Old 2"},
+			header:   "// New 1\n// New 2\n// New 3\n",
+			want:     "// New 1\n// New 2\n// New 3\n\n//go:build linux\n\npackage a\n",
+		},
+		{
+			name:     "File with only the header",
+			src:      "// Old 1",
+			oldLines: []string{"// Old 1"},
+			header:   "// New 1",
+			want:     "// New 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(UpgradeHeader([]byte(tt.src), tt.oldLines, []byte(tt.header))); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UpgradeHeader() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
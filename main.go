@@ -18,14 +18,19 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/elastic/go-licenser/licensing"
@@ -36,9 +41,13 @@ const (
 	defaultPath     = "."
 	defaultLicense  = "ASL2"
 	defaultLicensor = "Elasticsearch B.V."
-	defaultFormat   = "%s: is missing the license header\n"
+	defaultFormat   = formatText
 )
 
+// misplacedLines is the number of lines at the top of a file in which a
+// header which isn't in place is looked for.
+const misplacedLines = 100
+
 const (
 	exitDefault = iota
 	exitSourceNeedsToBeRewritten
@@ -48,14 +57,45 @@ const (
 	exitFailedToOpenWalkFile
 	errFailedRewrittingFile
 	errUnknownLicense
+	exitNoticeNeedsToBeRewritten
+	errFailedGeneratingNotice
+	exitDependenciesViolatePolicy
+	errInvalidPolicy
+	errFailedToListModules
+	errUnknownFormat
+	exitLicenseFileMismatch
+	errFailedWritingCache
+	errFailedInstallingHook
+	errFailedReadingIndex
+	exitInvalidDirective
+	exitForeignCopyright
+	errUnknownForeignCopyright
+	exitInterrupted
+	exitTimedOut
+	errUnsupportedEncoding
+	errFailedReadingArchive
+	errFailedReadingRevision
+	errModuleSourcesUnavailable
+	errInvalidVariants
+	errUnexpected
 )
 
 var usageText = `
 Usage: go-licenser [flags] [path]
+       go-licenser <command> [flags] [path]
 
   go-licenser walks the specified path recursively and appends a license Header if the current
   header doesn't match the one found in the file.
 
+Commands:
+
+  archive   checks the files of a .tar, .tar.gz or .zip archive, like a release or a Go module zip.
+  compare   reports the files whose license header regressed between two revisions or directories.
+  deps      checks the licenses of the module dependencies against a policy file.
+  hook      installs and runs a git pre-commit hook checking the staged files.
+  notice    generates or checks the NOTICE file from the module dependencies.
+  watch     adds the license header to the files as they are created.
+
 Options:
 
 `[1:]
@@ -63,15 +103,38 @@ Options:
 var (
 	dryRun             bool
 	copyright          bool
+	licenseFiles       bool
+	optOuts            bool
+	useCache           bool
+	cacheDir           string
 	showVersion        bool
+	showSummary        bool
+	failFast           bool
+	timeout            time.Duration
+	revision           string
+	variantsFile       string
+	pathBase           string
+	absPaths           bool
 	extension          string
 	args               []string
 	license            string
 	licensor           string
+	format             string
+	foreignCopyright   string
 	exclude            sliceFlag
 	defaultExludedDirs = []string{"vendor", ".git"}
 )
 
+// commands are the subcommands which can be passed as the first argument.
+var commands = map[string]func(args []string, out io.Writer) error{
+	"archive": archiveCommand,
+	"compare": compareCommand,
+	"deps":    depsCommand,
+	"hook":    hookCommand,
+	"notice":  noticeCommand,
+	"watch":   watchCommand,
+}
+
 type sliceFlag []string
 
 func (f *sliceFlag) String() string {
@@ -88,25 +151,40 @@ func (f *sliceFlag) Set(value string) error {
 }
 
 func initFlags() {
-	var licenseTypes []string
-	for k := range licensing.Headers {
-		licenseTypes = append(licenseTypes, k)
-	}
-	sort.Strings(licenseTypes)
+	var licenseTypes = licensing.Default.Keys()
 
 	flag.Var(&exclude, "exclude", `path to exclude (can be specified multiple times).`)
 	flag.BoolVar(&dryRun, "d", false, `skips rewriting files and returns exitcode 1 if any discrepancies are found.`)
 	flag.BoolVar(&showVersion, "version", false, `prints out the binary version.`)
+	flag.BoolVar(&failFast, "fail-fast", false, "stops at the first file which fails the check or can't be processed, instead of continuing with the rest of the tree.")
 	flag.BoolVar(&copyright, "copyright", false, "sets the copyright string as the first line")
+	flag.BoolVar(&optOuts, "opt-outs", false, "reports the files opting out of the license header with a go-licenser directive.")
+	flag.BoolVar(&licenseFiles, "license-files", false, "checks that the LICENSE files in the tree match the license type.")
+	flag.BoolVar(&useCache, "cache", false, "skips the files which haven't changed since they were last found to contain the header.")
+	flag.StringVar(&cacheDir, "cache-dir", "", "sets the directory where the cache is stored (default \"<user cache dir>/go-licenser\").")
+	flag.BoolVar(&showSummary, "summary", false, "prints a summary of the run with the counts per outcome, license and top-level directory.")
+	flag.StringVar(&pathBase, "path-base", "", "sets the directory the reported paths are relative to (default: the working directory).")
+	flag.BoolVar(&absPaths, "absolute-paths", false, "reports absolute paths instead of paths relative to the path base.")
+	flag.DurationVar(&timeout, "timeout", 0, "stops processing new files once the duration has elapsed, 0 means no timeout.")
+	flag.StringVar(&revision, "rev", "", "checks the files of the git revision, e.g. a tag, read from the repository of the path instead of the working tree. The files are never rewritten.")
 	flag.StringVar(&extension, "ext", defaultExt, "sets the file extension to scan for.")
 	flag.StringVar(&license, "license", defaultLicense, fmt.Sprintf("sets the license type to check: %s", strings.Join(licenseTypes, ", ")))
 	flag.StringVar(&licensor, "licensor", defaultLicensor, "sets the name of the licensor")
+	flag.StringVar(&foreignCopyright, "foreign-copyright", foreignReplace, fmt.Sprintf("sets how the headers holding the copyright notice of a third party are handled: %s", strings.Join(foreignModes, ", ")))
+	flag.StringVar(&variantsFile, "variants", "", "sets the JSON file of the former wordings of the headers, which are upgraded to the current one.")
+	flag.StringVar(&format, "format", defaultFormat, fmt.Sprintf("sets the report format: %s", strings.Join(reportFormats, ", ")))
 	flag.Usage = usageFlag
 	flag.Parse()
 	args = flag.Args()
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			exit(cmd(os.Args[2:], os.Stdout))
+		}
+	}
+
 	initFlags()
 
 	if showVersion {
@@ -114,7 +192,47 @@ func main() {
 		return
 	}
 
-	err := run(args, license, licensor, exclude, extension, copyright, dryRun, os.Stdout)
+	ctx, stop := runContext(timeout)
+	defer stop()
+
+	exit(run(ctx, args, options{
+		license:          license,
+		licensor:         licensor,
+		exclude:          exclude,
+		ext:              extension,
+		copyright:        copyright,
+		dry:              dryRun,
+		format:           format,
+		licenseFiles:     licenseFiles,
+		optOuts:          optOuts,
+		foreignCopyright: foreignCopyright,
+		cache:            useCache,
+		cacheDir:         cacheDir,
+		summary:          showSummary,
+		failFast:         failFast,
+		pathBase:         pathBase,
+		absPaths:         absPaths,
+		rev:              revision,
+		variants:         variantsFile,
+	}, os.Stdout))
+}
+
+// runContext returns the context of a run, which is done on SIGINT or
+// SIGTERM, or once the timeout has elapsed when it isn't 0.
+func runContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+func exit(err error) {
 	if err != nil && err.Error() != "<nil>" {
 		fmt.Fprint(os.Stderr, err)
 	}
@@ -122,99 +240,456 @@ func main() {
 	os.Exit(Code(err))
 }
 
-func run(args []string, license, licensor string, exclude []string, ext string, copyright bool, dry bool, out io.Writer) error {
-	header, ok := licensing.Headers[license]
-	if !ok {
-		return &Error{err: fmt.Errorf("unknown license: %s", license), code: errUnknownLicense}
-	}
+// options are the settings used to check or rewrite a tree.
+type options struct {
+	license   string
+	licensor  string
+	exclude   []string
+	ext       string
+	copyright bool
+	dry       bool
+	format    string
+
+	licenseFiles bool
+	optOuts      bool
+	cache        bool
+	cacheDir     string
+	summary      bool
+	failFast     bool
+	pathBase     string
+	absPaths     bool
+	rev          string
+
+	foreignCopyright string
+
+	// variants is the file of the former wordings of the headers, which are
+	// loaded into registry by run.
+	variants string
+	registry *licensing.Registry
+}
 
-	var headerBytes []byte
-	if copyright {
-	        year, _, _ := time.Now().Date()
-		headerBytes = append(headerBytes, []byte(fmt.Sprintf("// Copyright %d %s\n", year, licensor))...)
-	}
-	for i, line := range header {
-		if strings.Contains(line, "%s") {
-			header[i] = fmt.Sprintf(line, licensor)
-		}
-		headerBytes = append(headerBytes, []byte(header[i])...)
-		headerBytes = append(headerBytes, []byte("\n")...)
+// licenses returns the registry of the license headers, which is
+// licensing.Default unless the options set another one.
+func (opts options) licenses() *licensing.Registry {
+	if opts.registry != nil {
+		return opts.registry
 	}
+	return licensing.Default
+}
 
+func run(ctx context.Context, args []string, opts options, out io.Writer) error {
 	var path = defaultPath
 	if len(args) > 0 {
 		path = args[0]
 	}
 
-	if _, err := os.Stat(path); err != nil {
+	if opts.variants != "" {
+		registry, err := readVariants(opts.variants)
+		if err != nil {
+			return &Error{err: err, code: errInvalidVariants}
+		}
+		opts.registry = registry
+	}
+
+	if opts.rev != "" {
+		t, closeRepo, err := revTree(path, opts.rev)
+		if err != nil {
+			return err
+		}
+		defer closeRepo()
+
+		// The files of a revision can't be rewritten, nor cached since the
+		// objects have no modification time.
+		opts.dry, opts.cache = true, false
+		return runTree(ctx, t, opts, out)
+	}
+
+	return runTree(ctx, osTree(path), opts, out)
+}
+
+// readVariants returns a copy of licensing.Default holding the variants read
+// from the file found in path.
+func readVariants(path string) (*licensing.Registry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var registry = licensing.Default.Clone()
+	if err := registry.LoadVariants(f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return registry, nil
+}
+
+func runTree(ctx context.Context, t tree, opts options, out io.Writer) error {
+	var start = time.Now()
+	headerBytes, err := renderHeader(opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.foreignCopyright != "" && !stringInSlice(opts.foreignCopyright, foreignModes) {
+		return &Error{err: fmt.Errorf("unknown foreign copyright mode: %s", opts.foreignCopyright), code: errUnknownForeignCopyright}
+	}
+
+	r, err := newReporter(opts.format, out)
+	if err != nil {
+		return &Error{err: err, code: errUnknownFormat}
+	}
+	if r, err = newPathReporter(r, opts.pathBase, opts.absPaths, t); err != nil {
+		return &Error{err: err, code: exitFailedToStatTree}
+	}
+
+	if _, err := fs.Stat(t.fsys, t.root); err != nil {
 		return &Error{err: err, code: exitFailedToStatTree}
 	}
 
-	return walk(path, ext, license, headerBytes, exclude, dry, out)
+	var hc *checkCache
+	if opts.cache {
+		if hc, err = newRunCache(t.path(t.root), headerBytes, opts); err != nil {
+			return &Error{err: err, code: errFailedWritingCache}
+		}
+	}
+
+	var s *summary
+	if opts.summary {
+		s = newSummary(t.path(t.root))
+	}
+
+	var errs Errors
+	var walkStart = time.Now()
+	var walkErr = walk(ctx, t, headerBytes, opts, r, hc, s)
+	errs.Add(walkErr)
+	if hc != nil && ctx.Err() == nil && (!opts.failFast || walkErr == nil) {
+		hc.Prune()
+	}
+	if opts.licenseFiles && ctx.Err() == nil && (!opts.failFast || errs.Err() == nil) {
+		errs.Add(checkLicenseFiles(t, opts, r))
+		errs.Add(checkRootLicenseFile(t, r))
+	}
+	if s != nil {
+		s.done(start, time.Since(walkStart))
+		r.Summarize(s)
+	}
+	errs.Add(r.Flush())
+	if hc != nil {
+		if err := hc.Save(); err != nil {
+			errs.Add(&Error{err: err, code: errFailedWritingCache})
+		}
+	}
+	return errs.Err()
 }
 
-func reportFile(out io.Writer, f string) {
-	cwd, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-	rel, err := filepath.Rel(cwd, f)
+// renderHeader formats the licensor into the header of the license and returns
+// the bytes which are written to the files missing it.
+func renderHeader(opts options) ([]byte, error) {
+	header, err := opts.licenses().Render(opts.license, licensing.Params{Licensor: opts.licensor})
 	if err != nil {
-		rel = f
+		return nil, &Error{err: err, code: errUnknownLicense}
+	}
+
+	var headerBytes []byte
+	if opts.copyright {
+		headerBytes = append(headerBytes, []byte(copyrightLine(opts)+"\n")...)
 	}
-	fmt.Fprintf(out, defaultFormat, rel)
+	for _, line := range header {
+		headerBytes = append(headerBytes, []byte(line)...)
+		headerBytes = append(headerBytes, []byte("\n")...)
+	}
+
+	return headerBytes, nil
 }
 
-func walk(p, ext, license string, headerBytes []byte, exclude []string, dry bool, out io.Writer) error {
-	var err error
-	filepath.WalkDir(p, func(path string, info fs.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			err = &Error{err: walkErr, code: exitFailedToWalkPath}
-			return walkErr
+// copyrightLine returns the copyright string of the licensor for the current
+// year, which starts the header with -copyright.
+func copyrightLine(opts options) string {
+	year, _, _ := time.Now().Date()
+	return fmt.Sprintf("// Copyright %d %s", year, opts.licensor)
+}
+
+// headerLines returns the lines of the header of the license rendered with
+// the licensor, which the contents src are checked against. With -copyright,
+// they start with the copyright string of the licensor found in src, whatever
+// its year, or with the one of the current year, so that the header is found
+// as a whole.
+func headerLines(src []byte, license string, opts options) []string {
+	lines, _ := opts.licenses().Render(license, licensing.Params{Licensor: opts.licensor})
+	if opts.copyright && len(lines) > 0 {
+		lines = append([]string{foundCopyrightLine(src, opts)}, lines...)
+	}
+	return lines
+}
+
+// foundCopyrightLine returns the first copyright string of the licensor found
+// in src, with any year or range of years, or the one of the current year
+// when there's none.
+func foundCopyrightLine(src []byte, opts options) string {
+	var scanner = bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(src, []byte("\ufeff"))))
+	for scanner.Scan() {
+		var line = strings.TrimSuffix(scanner.Text(), "\r")
+		if isCopyrightLine(line, opts.licensor) {
+			return line
 		}
+	}
+	return copyrightLine(opts)
+}
 
-		var currentPath = cleanPathPrefixes(
-			strings.TrimLeft(path, p),
-			[]string{string(os.PathSeparator)},
-		)
+// isCopyrightLine returns true when line is the copyright string of the
+// licensor, as in "// Copyright 2019 Elasticsearch B.V." or
+// "// Copyright 2019-2024 Elasticsearch B.V.".
+func isCopyrightLine(line, licensor string) bool {
+	rest, ok := strings.CutPrefix(line, "// Copyright ")
+	if !ok {
+		return false
+	}
+	years, name, ok := strings.Cut(rest, " ")
+	if !ok || name != licensor {
+		return false
+	}
+	from, to, ranged := strings.Cut(years, "-")
+	return isYear(from) && (!ranged || isYear(to))
+}
 
-		var excludedDir = info.IsDir() && stringInSlice(info.Name(), defaultExludedDirs)
-		if needsExclusion(currentPath, exclude) || excludedDir {
-			return filepath.SkipDir
+func isYear(s string) bool {
+	if len(s) != 4 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
 		}
+	}
+	return true
+}
 
-		if e := addOrCheckLicense(path, ext, license, headerBytes, info, dry, out); e != nil {
-			err = e
+// headerLicense returns the key of the license of the registry whose header
+// starts src, or an empty string when there's none. The longest header wins,
+// since a header can start with the lines of another one.
+func headerLicense(src []byte, opts options) string {
+	var license string
+	var size int
+	for _, k := range opts.licenses().Keys() {
+		var lines = headerLines(src, k, opts)
+		if len(lines) > size && licensing.ContainsHeader(bytes.NewReader(src), lines) {
+			license, size = k, len(lines)
 		}
+	}
+	return license
+}
+
+// reportFile reports a finding for the file found in path. The path is made
+// relative to the path base by the reporter returned from newPathReporter.
+func reportFile(r reporter, path, kind, message string) {
+	r.Report(finding{Path: path, Kind: kind, Message: message})
+}
+
+// walk checks or fixes the files of the tree until the context is done, after
+// which no new files are processed.
+func walk(ctx context.Context, t tree, headerBytes []byte, opts options, r reporter, hc *checkCache, s *summary) error {
+	var errs Errors
+	var excluded = newExclusions(t.path(t.root), opts.exclude)
 
+	// stop skips the rest of the tree after an error in fail fast mode.
+	var stop = func(err error) error {
+		if errs.Add(err) && opts.failFast {
+			return fs.SkipAll
+		}
 		return nil
+	}
+
+	fs.WalkDir(t.fsys, t.root, func(name string, info fs.DirEntry, walkErr error) error {
+		if err := ctx.Err(); err != nil {
+			errs.Add(cancelled(err))
+			return fs.SkipAll
+		}
+		if walkErr != nil {
+			return stop(&Error{err: walkErr, code: exitFailedToWalkPath})
+		}
+
+		var excludedDir = name != t.root && info.IsDir() && stringInSlice(info.Name(), defaultExludedDirs)
+		if t.excluded(excluded, name) || excludedDir {
+			s.exclude()
+			if info.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		// The license files are checked once the headers of the tree are
+		// known, by checkLicenseFiles.
+		if opts.licenseFiles && !info.IsDir() && isLicenseFile(info.Name()) {
+			return nil
+		}
+
+		return stop(addOrCheckLicense(t, name, headerBytes, info, opts, r, hc, s))
 	})
 
-	return err
+	return errs.Err()
 }
 
-func addOrCheckLicense(path, ext, license string, headerBytes []byte, info fs.DirEntry, dry bool, out io.Writer) error {
-	if info.IsDir() || filepath.Ext(path) != ext {
+// addOrCheckLicense checks the file name of the tree and, unless in dry mode,
+// fixes it.
+func addOrCheckLicense(t tree, name string, headerBytes []byte, info fs.DirEntry, opts options, r reporter, hc *checkCache, s *summary) (err error) {
+	if info.IsDir() || filepath.Ext(name) != opts.ext {
 		return nil
 	}
 
-	f, e := os.Open(path)
+	var path = t.path(name)
+
+	// The file is fixed unless it's found to be compliant or opted out before
+	// getting to the rewrite, any error makes it count as failing.
+	var license, status = opts.license, statusFixed
+	defer func() { s.record(path, license, status, err) }()
+
+	var fi fs.FileInfo
+	if hc != nil {
+		var e error
+		if fi, e = info.Info(); e != nil {
+			return &Error{err: e, code: exitFailedToStatFile}
+		}
+		if hc.Compliant(t, name, fi) {
+			status = statusCompliant
+			return nil
+		}
+	}
+
+	// Only the preamble of the file, which holds the header, is checked.
+	preamble, e := readPreamble(t, name)
 	if e != nil {
-		return &Error{err: e, code: exitFailedToOpenWalkFile}
+		return e
 	}
-	defer f.Close()
 
-	if licensing.ContainsHeader(f, licensing.Headers[license]) {
+	if licensing.IsUTF16(preamble) {
+		return &Error{err: fmt.Errorf("%s: %w", path, licensing.ErrUTF16), code: errUnsupportedEncoding}
+	}
+	if reason, binary := licensing.IsBinary(preamble); binary {
+		status = statusSkipped
+		reportFile(r, path, findingBinary, "is skipped as a binary file, "+reason)
 		return nil
 	}
 
-	if dry {
-		reportFile(out, path)
+	if d := licensing.ParseDirectives(bytes.NewReader(preamble)); d.Present() {
+		license = d.License
+		if done, e := checkDirectives(path, d, preamble, opts, r); done {
+			status = statusOptedOut
+			return e
+		}
+
+		var licenseOpts = opts
+		licenseOpts.license = license
+		if headerBytes, e = renderHeader(licenseOpts); e != nil {
+			return e
+		}
+	}
+	if licensing.ContainsHeader(bytes.NewReader(preamble), headerLines(preamble, license, opts)) {
+		status = statusCompliant
+		fixed, e := checkPreamble(t, name, license, preamble, headerBytes, opts, r)
+		if e != nil {
+			return e
+		}
+		if fixed {
+			status = statusFixed
+			return nil
+		}
+		if hc != nil && license == opts.license {
+			if e := hc.Store(t, name, fi); e != nil {
+				return &Error{err: e, code: exitFailedToOpenWalkFile}
+			}
+		}
+		return nil
+	}
+
+	if v, ok := opts.licenses().FindVariant(bytes.NewReader(preamble), license, opts.licensor); ok {
+		return upgradeFile(t, name, preamble, v, headerBytes, opts, r)
+	}
+
+	var lines = headerLines(preamble, license, opts)
+	if line, ok := licensing.FindHeader(bytes.NewReader(preamble), lines, misplacedLines); ok {
+		return moveHeader(t, name, preamble, line, len(lines), opts, r)
+	}
+
+	if opts.foreignCopyright != "" && opts.foreignCopyright != foreignReplace {
+		return rewriteFile(t, name, headerBytes, opts, r)
+	}
+
+	if opts.dry {
+		reportFile(r, path, findingMissingHeader, "is missing the license header")
 		return &Error{code: exitSourceNeedsToBeRewritten}
 	}
 
-	if err := licensing.RewriteFileWithHeader(path, headerBytes); err != nil {
-		return &Error{err: err, code: errFailedRewrittingFile}
+	return rewritePreamble(t, name, preamble, licensing.RewriteSourceWithHeader(name, preamble, headerBytes))
+}
+
+// upgradeFile replaces the outdated variant of the header found in the
+// preamble of the file name of the tree. In dry mode the file is only reported.
+func upgradeFile(t tree, name string, preamble []byte, v licensing.Variant, headerBytes []byte, opts options, r reporter) error {
+	if opts.dry {
+		reportFile(r, t.path(name), findingOutdatedHeader, fmt.Sprintf("has an outdated header (variant %s)", v.Name))
+		return &Error{code: exitSourceNeedsToBeRewritten}
 	}
 
-	return nil
+	return rewritePreamble(t, name, preamble, licensing.UpgradeHeader(preamble, v.Render(opts.licensor), headerBytes))
+}
+
+// moveHeader moves the header found at the line index of the preamble of the
+// file name of the tree to the top of the file, instead of adding it again. In
+// dry mode the file is only reported.
+func moveHeader(t tree, name string, preamble []byte, line, n int, opts options, r reporter) error {
+	if opts.dry {
+		reportFile(r, t.path(name), findingMisplaced, fmt.Sprintf("has the license header at line %d instead of the top of the file", line+1))
+		return &Error{code: exitSourceNeedsToBeRewritten}
+	}
+
+	return rewritePreamble(t, name, preamble, licensing.MoveHeader(preamble, line, n))
+}
+
+// checkPreamble checks what follows the license header in the preamble of
+// the file name of the tree: that no other license header is stacked below it
+// and, for Go files, that it isn't attached to the package clause as its
+// documentation. Unless in dry mode, the file is fixed, in which case it
+// returns true.
+func checkPreamble(t tree, name, license string, preamble, headerBytes []byte, opts options, r reporter) (bool, error) {
+	var path = t.path(name)
+	var fixed = preamble
+	var params = licensing.Params{Licensor: opts.licensor}
+	if found, start, end := opts.licenses().StackedHeaders(preamble, headerLines(preamble, license, opts), params); len(found) > 0 {
+		if opts.dry {
+			reportFile(r, path, findingDuplicateHeader, fmt.Sprintf("has more license headers stacked below the header: %s", strings.Join(found, ", ")))
+		}
+		fixed = licensing.CollapseHeaders(fixed, start, end)
+	}
+
+	// Go files which can't be parsed are left to the compiler.
+	if filepath.Ext(path) == ".go" {
+		if doc, _ := licensing.HeaderIsPackageDoc(fixed); doc {
+			if opts.dry {
+				reportFile(r, path, findingPackageDoc, "has the license header attached to the package clause as its documentation")
+			}
+			var err error
+			if fixed, err = licensing.RewriteGoWithHeader(fixed, headerBytes); err != nil {
+				return false, &Error{err: err, code: errFailedRewrittingFile}
+			}
+		}
+	}
+
+	if bytes.Equal(fixed, preamble) {
+		return false, nil
+	}
+	if opts.dry {
+		return false, &Error{code: exitSourceNeedsToBeRewritten}
+	}
+	return true, rewritePreamble(t, name, preamble, fixed)
+}
+
+// cancelled returns the error of a run stopped by the context, with its own
+// exit code depending on whether it timed out or was interrupted.
+func cancelled(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{err: fmt.Errorf("timed out, the report is partial: %w", err), code: exitTimedOut}
+	}
+	return &Error{err: fmt.Errorf("interrupted, the report is partial: %w", err), code: exitInterrupted}
 }
 
 func stringInSlice(a string, list []string) bool {
@@ -19,25 +19,29 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/elastic/go-licenser/licensing"
 )
 
 var update = flag.Bool("update", false, "updates the golden files with the latest iteration of the code")
 
 func Test_run(t *testing.T) {
 	type args struct {
-		args     []string
-		license  string
-		licensor string
-		exclude  []string
-		ext      string
-		copyright bool
-		dry      bool
+		args []string
+		opts options
 	}
 	tests := []struct {
 		name       string
@@ -50,17 +54,20 @@ func Test_run(t *testing.T) {
 		{
 			name: "Run a diff prints a list of files that need the license header",
 			args: args{
-				args:     []string{"testdata"},
-				license:  defaultLicense,
-				licensor: defaultLicensor,
-				exclude:  []string{"excludedpath", "x-pack", "x-pack-v2", "cloud"},
-				ext:      defaultExt,
-                                copyright: false,
-				dry:      true,
+				args: []string{"testdata"},
+				opts: options{
+					license:  defaultLicense,
+					licensor: defaultLicensor,
+					exclude:  []string{"excludedpath", "x-pack", "x-pack-v2", "cloud"},
+					ext:      defaultExt,
+					dry:      true,
+				},
 			},
 			want: 1,
 			err:  &Error{code: 1},
 			wantOutput: `
+testdata/misplaced/afterpackage.go: has the license header at line 3 instead of the top of the file
+testdata/misplaced/buildtags.go: has the license header at line 4 instead of the top of the file
 testdata/multilevel/doc.go: is missing the license header
 testdata/multilevel/main.go: is missing the license header
 testdata/multilevel/sublevel/autogen.go: is missing the license header
@@ -74,12 +81,13 @@ testdata/singlelevel/wrapper.go: is missing the license header
 		{
 			name: "Run a diff prints a list of files that need the Elastic license header",
 			args: args{
-				args:     []string{"testdata"},
-				license:  "Elastic",
-				licensor: defaultLicensor,
-				ext:      defaultExt,
-                                copyright: false,
-				dry:      true,
+				args: []string{"testdata"},
+				opts: options{
+					license:  "Elastic",
+					licensor: defaultLicensor,
+					ext:      defaultExt,
+					dry:      true,
+				},
 			},
 			want: 1,
 			err:  &Error{code: 1},
@@ -87,6 +95,8 @@ testdata/singlelevel/wrapper.go: is missing the license header
 testdata/cloud/doc.go: is missing the license header
 testdata/cloud/wrong.go: is missing the license header
 testdata/excludedpath/file.go: is missing the license header
+testdata/misplaced/afterpackage.go: is missing the license header
+testdata/misplaced/buildtags.go: is missing the license header
 testdata/multilevel/doc.go: is missing the license header
 testdata/multilevel/main.go: is missing the license header
 testdata/multilevel/sublevel/autogen.go: is missing the license header
@@ -104,12 +114,13 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 		{
 			name: "Run a diff prints a list of files that need the Elastic license 2.0 header",
 			args: args{
-				args:     []string{"testdata"},
-				license:  "Elasticv2",
-				licensor: defaultLicensor,
-				ext:      defaultExt,
-                                copyright: false,
-				dry:      true,
+				args: []string{"testdata"},
+				opts: options{
+					license:  "Elasticv2",
+					licensor: defaultLicensor,
+					ext:      defaultExt,
+					dry:      true,
+				},
 			},
 			want: 1,
 			err:  &Error{code: 1},
@@ -117,6 +128,8 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 testdata/cloud/doc.go: is missing the license header
 testdata/cloud/wrong.go: is missing the license header
 testdata/excludedpath/file.go: is missing the license header
+testdata/misplaced/afterpackage.go: is missing the license header
+testdata/misplaced/buildtags.go: is missing the license header
 testdata/multilevel/doc.go: is missing the license header
 testdata/multilevel/main.go: is missing the license header
 testdata/multilevel/sublevel/autogen.go: is missing the license header
@@ -134,18 +147,21 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 		{
 			name: "Run a diff prints a list of files that need the Cloud license header",
 			args: args{
-				args:     []string{"testdata"},
-				license:  "Cloud",
-				licensor: defaultLicensor,
-				ext:      defaultExt,
-                                copyright: false,
-				dry:      true,
+				args: []string{"testdata"},
+				opts: options{
+					license:  "Cloud",
+					licensor: defaultLicensor,
+					ext:      defaultExt,
+					dry:      true,
+				},
 			},
 			want: 1,
 			err:  &Error{code: 1},
 			wantOutput: `
 testdata/cloud/wrong.go: is missing the license header
 testdata/excludedpath/file.go: is missing the license header
+testdata/misplaced/afterpackage.go: is missing the license header
+testdata/misplaced/buildtags.go: is missing the license header
 testdata/multilevel/doc.go: is missing the license header
 testdata/multilevel/main.go: is missing the license header
 testdata/multilevel/sublevel/autogen.go: is missing the license header
@@ -161,15 +177,57 @@ testdata/x-pack-v2/correct.go: is missing the license header
 testdata/x-pack-v2/wrong.go: is missing the license header
 `[1:],
 		},
+		{
+			name: "Run a diff prints the files that need the license header as JSON",
+			args: args{
+				args: []string{"testdata"},
+				opts: options{
+					license:  "Cloud",
+					licensor: defaultLicensor,
+					exclude:  []string{"excludedpath", "misplaced", "multilevel", "singlelevel", "x-pack", "x-pack-v2"},
+					ext:      defaultExt,
+					dry:      true,
+					format:   formatJSON,
+				},
+			},
+			want: 1,
+			err:  &Error{code: 1},
+			wantOutput: `
+{
+  "findings": [
+    {
+      "path": "testdata/cloud/wrong.go",
+      "kind": "missing-header",
+      "message": "is missing the license header"
+    }
+  ]
+}
+`[1:],
+		},
+		{
+			name: "Unknown format fails",
+			args: args{
+				args: []string{"ignore"},
+				opts: options{
+					license:  defaultLicense,
+					licensor: defaultLicensor,
+					ext:      defaultExt,
+					format:   "xml",
+				},
+			},
+			want: errUnknownFormat,
+			err:  &Error{err: errors.New("unknown format: xml"), code: errUnknownFormat},
+		},
 		{
 			name: "Run against an unexisting dir fails",
 			args: args{
-				args:     []string{"ignore"},
-				license:  defaultLicense,
-				licensor: defaultLicensor,
-				ext:      defaultExt,
-                                copyright: false,
-				dry:      false,
+				args: []string{"ignore"},
+				opts: options{
+					license:  defaultLicense,
+					licensor: defaultLicensor,
+					ext:      defaultExt,
+					dry:      false,
+				},
 			},
 			want: 2,
 			err:  goosPathError(2, "ignore"),
@@ -177,12 +235,13 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 		{
 			name: "Unknown license fails",
 			args: args{
-				args:     []string{"ignore"},
-				license:  "foo",
-				licensor: defaultLicensor,
-				ext:      defaultExt,
-                                copyright: false,
-				dry:      false,
+				args: []string{"ignore"},
+				opts: options{
+					license:  "foo",
+					licensor: defaultLicensor,
+					ext:      defaultExt,
+					dry:      false,
+				},
 			},
 			want: 7,
 			err:  &Error{err: errors.New("unknown license: foo"), code: 7},
@@ -190,13 +249,14 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 		{
 			name: "Check ASL2 license rewrite",
 			args: args{
-				args:     []string{"testdata"},
-				license:  defaultLicense,
-				licensor: defaultLicensor,
-				exclude:  []string{"excludedpath"},
-				ext:      defaultExt,
-                                copyright: false,
-				dry:      false,
+				args: []string{"testdata"},
+				opts: options{
+					license:  defaultLicense,
+					licensor: defaultLicensor,
+					exclude:  []string{"excludedpath"},
+					ext:      defaultExt,
+					dry:      false,
+				},
 			},
 			want:       0,
 			wantGolden: true,
@@ -204,13 +264,14 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 		{
 			name: "Check ASL2-short license rewrite",
 			args: args{
-				args:     []string{"testdata"},
-				license:  "ASL2-Short",
-				licensor: defaultLicensor,
-				exclude:  []string{"excludedpath"},
-				ext:      defaultExt,
-                                copyright: false,
-				dry:      false,
+				args: []string{"testdata"},
+				opts: options{
+					license:  "ASL2-Short",
+					licensor: defaultLicensor,
+					exclude:  []string{"excludedpath"},
+					ext:      defaultExt,
+					dry:      false,
+				},
 			},
 			want:       0,
 			wantGolden: true,
@@ -218,13 +279,14 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 		{
 			name: "Check Cloud license rewrite",
 			args: args{
-				args:     []string{"testdata"},
-				license:  "Cloud",
-				licensor: defaultLicensor,
-				exclude:  []string{"excludedpath"},
-				ext:      defaultExt,
-                                copyright: false,
-				dry:      false,
+				args: []string{"testdata"},
+				opts: options{
+					license:  "Cloud",
+					licensor: defaultLicensor,
+					exclude:  []string{"excludedpath"},
+					ext:      defaultExt,
+					dry:      false,
+				},
 			},
 			want:       0,
 			wantGolden: true,
@@ -232,13 +294,14 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 		{
 			name: "Check Elastic license rewrite",
 			args: args{
-				args:     []string{"testdata"},
-				license:  "Elastic",
-				licensor: defaultLicensor,
-				exclude:  []string{"excludedpath"},
-				ext:      defaultExt,
-                                copyright: false,
-				dry:      false,
+				args: []string{"testdata"},
+				opts: options{
+					license:  "Elastic",
+					licensor: defaultLicensor,
+					exclude:  []string{"excludedpath"},
+					ext:      defaultExt,
+					dry:      false,
+				},
 			},
 			want:       0,
 			wantGolden: true,
@@ -246,13 +309,14 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 		{
 			name: "Check Elastic 2.0 license rewrite",
 			args: args{
-				args:     []string{"testdata"},
-				license:  "Elasticv2",
-				licensor: defaultLicensor,
-				exclude:  []string{"excludedpath"},
-				ext:      defaultExt,
-                                copyright: false,
-				dry:      false,
+				args: []string{"testdata"},
+				opts: options{
+					license:  "Elasticv2",
+					licensor: defaultLicensor,
+					exclude:  []string{"excludedpath"},
+					ext:      defaultExt,
+					dry:      false,
+				},
 			},
 			want:       0,
 			wantGolden: true,
@@ -265,7 +329,7 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 			}
 
 			var buf = new(bytes.Buffer)
-			var err = run(tt.args.args, tt.args.license, tt.args.licensor, tt.args.exclude, tt.args.ext, tt.args.copyright, tt.args.dry, buf)
+			var err = run(context.Background(), tt.args.args, tt.args.opts, buf)
 			if !reflect.DeepEqual(err, tt.err) {
 				t.Errorf("run() error = %v, wantErr %v", err, tt.err)
 				return
@@ -283,10 +347,10 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 			}
 
 			if tt.wantGolden {
-				goldenDirectory := filepath.Join("golden", tt.args.license)
+				goldenDirectory := filepath.Join("golden", tt.args.opts.license)
 				if *update {
 					copyFixtures(t, goldenDirectory)
-					if err := run([]string{goldenDirectory}, tt.args.license, tt.args.licensor, tt.args.exclude, tt.args.ext, tt.args.copyright, tt.args.dry, buf); err != nil {
+					if err := run(context.Background(), []string{goldenDirectory}, tt.args.opts, buf); err != nil {
 						t.Fatal(err)
 					}
 				}
@@ -296,6 +360,129 @@ testdata/x-pack-v2/wrong.go: is missing the license header
 	}
 }
 
+func Test_renderHeader(t *testing.T) {
+	// Rendering a header must not change the one rendered for the next call.
+	for _, licensor := range []string{defaultLicensor, "Someone Else", defaultLicensor} {
+		header, err := renderHeader(options{license: defaultLicense, licensor: licensor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want = "// Licensed to " + licensor + " under one or more contributor\n"
+		if !bytes.HasPrefix(header, []byte(want)) {
+			t.Errorf("renderHeader(%s) = %q, want the prefix %q", licensor, header, want)
+		}
+	}
+
+	if _, err := renderHeader(options{license: "WTFPL"}); Code(err) != errUnknownLicense {
+		t.Errorf("renderHeader() = %v, want %v", Code(err), errUnknownLicense)
+	}
+}
+
+func Test_run_outdatedHeader(t *testing.T) {
+	// None of the headers shipped has been reworded, the variant is only
+	// known to a registry local to the test.
+	var registry = licensing.NewRegistry()
+	lines, _ := licensing.Default.Lookup(defaultLicense)
+	if err := registry.Register(defaultLicense, lines); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.RegisterVariant(defaultLicense, licensing.Variant{
+		Name:  "2019",
+		Lines: []string{"// Licensed to %s under the Apache License 2.0."},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := renderHeader(options{license: defaultLicense, licensor: defaultLicensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outdated = "// Licensed to Elasticsearch B.V. under the Apache License 2.0.\n\n//go:build linux\n\npackage main\n"
+	for _, dry := range []bool{true, false} {
+		t.Run(fmt.Sprintf("dry=%v", dry), func(t *testing.T) {
+			var fsys = fixturesFS(t, map[string]string{"outdated.src": outdated})
+
+			var buf = new(bytes.Buffer)
+			err := runFS(context.Background(), fsys, options{
+				license:  defaultLicense,
+				licensor: defaultLicensor,
+				ext:      ".src",
+				dry:      dry,
+				registry: registry,
+			}, buf)
+
+			var want, wantOutput, wantContents = exitDefault, "", string(header) + "\n//go:build linux\n\npackage main\n"
+			if dry {
+				want, wantOutput, wantContents = exitSourceNeedsToBeRewritten, "outdated.src: has an outdated header (variant 2019)\n", outdated
+			}
+			if got := Code(err); got != want {
+				t.Errorf("run() = %v, want %v", got, want)
+			}
+			if got := buf.String(); got != wantOutput {
+				t.Errorf("Output = %q, want %q", got, wantOutput)
+			}
+			if got, _ := fs.ReadFile(fsys, "outdated.src"); string(got) != wantContents {
+				t.Errorf("outdated.src = \n%s\n want \n%s", got, wantContents)
+			}
+		})
+	}
+}
+
+func Test_run_variants(t *testing.T) {
+	var dir = t.TempDir()
+	var variants = filepath.Join(t.TempDir(), "variants.json")
+	writeFiles(t, dir, map[string]string{
+		"outdated.go": "// Licensed to Elasticsearch B.V. under the Apache License 2.0.\n\npackage main\n",
+	})
+
+	var opts = options{
+		license:  defaultLicense,
+		licensor: defaultLicensor,
+		ext:      defaultExt,
+		dry:      true,
+		variants: variants,
+	}
+	for _, tt := range []struct {
+		name       string
+		variants   string
+		want       int
+		wantOutput string
+	}{
+		{
+			name:       "Variants are upgraded",
+			variants:   `{"ASL2": [{"name": "2019", "lines": ["// Licensed to %s under the Apache License 2.0."]}]}`,
+			want:       exitSourceNeedsToBeRewritten,
+			wantOutput: "outdated.go: has an outdated header (variant 2019)\n",
+		},
+		{
+			name:     "Variants of unknown licenses are invalid",
+			variants: `{"Unknown": [{"name": "2019", "lines": ["// Licensed"]}]}`,
+			want:     errInvalidVariants,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(variants, []byte(tt.variants), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf = new(bytes.Buffer)
+			var err = run(context.Background(), []string{dir}, opts, buf)
+			if got := Code(err); got != tt.want {
+				t.Errorf("run() = %v, want %v (%v)", got, tt.want, err)
+			}
+			// The path of the file is reported relative to the working directory.
+			if got := buf.String(); !strings.HasSuffix(got, tt.wantOutput) || (tt.wantOutput == "" && got != "") {
+				t.Errorf("Output = %q, want %q", got, tt.wantOutput)
+			}
+		})
+	}
+
+	if got := licensing.Default.Variants(defaultLicense); len(got) != 0 {
+		t.Errorf("licensing.Default variants = %v, want none", got)
+	}
+}
+
 func BenchmarkRun(b *testing.B) {
 	args := []string{"."}
 	excluded := append(defaultExludedDirs, "golden")
@@ -304,7 +491,410 @@ func BenchmarkRun(b *testing.B) {
 		b.ReportAllocs()
 
 		for i := 0; i < b.N; i++ {
-			run(args, defaultLicense, defaultLicensor, excluded, defaultExt, false, false, os.Stdout)
+			run(context.Background(), args, options{
+				license:  defaultLicense,
+				licensor: defaultLicensor,
+				exclude:  excluded,
+				ext:      defaultExt,
+			}, os.Stdout)
 		}
 	})
 }
+
+func Test_run_cancelled(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want int
+	}{
+		{name: "Interrupted", ctx: cancelled, want: exitInterrupted},
+		{name: "Timed out", ctx: expired, want: exitTimedOut},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf = new(bytes.Buffer)
+			var err = runFS(tt.ctx, fixturesFS(t, nil), options{
+				license:  defaultLicense,
+				licensor: defaultLicensor,
+				ext:      defaultExt,
+				format:   formatJSON,
+				summary:  true,
+			}, buf)
+			if got := Code(err); got != tt.want {
+				t.Errorf("run() = %v, want %v", got, tt.want)
+			}
+
+			// The partial report is still written, without any file.
+			var doc struct {
+				Summary summary `json:"summary"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if doc.Summary.Scanned != 0 {
+				t.Errorf("Scanned = %d, want 0", doc.Summary.Scanned)
+			}
+		})
+	}
+}
+
+func Test_run_packageDoc(t *testing.T) {
+	header, err := renderHeader(options{license: defaultLicense, licensor: defaultLicensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dry := range []bool{true, false} {
+		t.Run(fmt.Sprintf("dry=%v", dry), func(t *testing.T) {
+			var dir = t.TempDir()
+			var path = filepath.Join(dir, "doc.go")
+			if err := os.WriteFile(path, append(header, "package main\n"...), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf = new(bytes.Buffer)
+			var err = run(context.Background(), []string{dir}, options{
+				license:  defaultLicense,
+				licensor: defaultLicensor,
+				ext:      defaultExt,
+				dry:      dry,
+				pathBase: dir,
+			}, buf)
+
+			var want, wantOutput = 0, ""
+			var wantSrc = string(header) + "\npackage main\n"
+			if dry {
+				want = exitSourceNeedsToBeRewritten
+				wantOutput = "doc.go: has the license header attached to the package clause as its documentation\n"
+				wantSrc = string(header) + "package main\n"
+			}
+			if got := Code(err); got != want {
+				t.Errorf("run() = %v, want %v", got, want)
+			}
+			if got := buf.String(); got != wantOutput {
+				t.Errorf("Output = %q, want %q", got, wantOutput)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != wantSrc {
+				t.Errorf("doc.go = \n%s\n want \n%s", got, wantSrc)
+			}
+		})
+	}
+}
+
+func Test_run_duplicateHeader(t *testing.T) {
+	header, err := renderHeader(options{license: defaultLicense, licensor: defaultLicensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	elastic, err := renderHeader(options{license: "Elastic", licensor: defaultLicensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var src = string(header) + "\n" + string(elastic) + "\n" + string(header) + "\npackage main\n"
+
+	for _, dry := range []bool{true, false} {
+		t.Run(fmt.Sprintf("dry=%v", dry), func(t *testing.T) {
+			var dir = t.TempDir()
+			var path = filepath.Join(dir, "stacked.go")
+			if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf = new(bytes.Buffer)
+			var err = run(context.Background(), []string{dir}, options{
+				license:  defaultLicense,
+				licensor: defaultLicensor,
+				ext:      defaultExt,
+				dry:      dry,
+				pathBase: dir,
+			}, buf)
+
+			var want, wantOutput = 0, ""
+			var wantSrc = string(header) + "\npackage main\n"
+			if dry {
+				want = exitSourceNeedsToBeRewritten
+				wantOutput = "stacked.go: has more license headers stacked below the header: Elastic, ASL2\n"
+				wantSrc = src
+			}
+			if got := Code(err); got != want {
+				t.Errorf("run() = %v, want %v", got, want)
+			}
+			if got := buf.String(); got != wantOutput {
+				t.Errorf("Output = %q, want %q", got, wantOutput)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != wantSrc {
+				t.Errorf("stacked.go = \n%s\n want \n%s", got, wantSrc)
+			}
+		})
+	}
+}
+
+func Test_run_copyright(t *testing.T) {
+	var opts = options{
+		license:   defaultLicense,
+		licensor:  defaultLicensor,
+		ext:       defaultExt,
+		copyright: true,
+	}
+	header, err := renderHeader(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dir = t.TempDir()
+	var path = filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second run must find the header written by the first one in place,
+	// copyright string included, and leave the file alone.
+	var want = string(header) + "\npackage main\n"
+	for i, dry := range []bool{false, false, true} {
+		var buf = new(bytes.Buffer)
+		opts.dry = dry
+		if err := run(context.Background(), []string{dir}, opts, buf); err != nil {
+			t.Fatalf("run #%d: %v: %s", i+1, err, buf)
+		}
+		if got := buf.String(); got != "" {
+			t.Errorf("run #%d: Output = %q, want none", i+1, got)
+		}
+
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("run #%d: main.go = \n%s\n want \n%s", i+1, got, want)
+		}
+	}
+}
+
+func Test_run_copyrightYears(t *testing.T) {
+	var opts = options{
+		license:   defaultLicense,
+		licensor:  defaultLicensor,
+		ext:       defaultExt,
+		copyright: true,
+		dry:       true,
+	}
+	lines, _ := licensing.Default.Render(defaultLicense, licensing.Params{Licensor: defaultLicensor})
+	var header = strings.Join(lines, "\n") + "\n"
+
+	// The copyright string written in a former year is still the licensor's.
+	var dir = t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"year.go":  "// Copyright 2019 Elasticsearch B.V.\n" + header + "\npackage main\n",
+		"range.go": "// Copyright 2019-2024 Elasticsearch B.V.\n" + header + "\npackage main\n",
+	})
+	var buf = new(bytes.Buffer)
+	if err := run(context.Background(), []string{dir}, opts, buf); err != nil || buf.Len() > 0 {
+		t.Errorf("run() = %v, %q with copyright strings of former years", err, buf.String())
+	}
+
+	writeFiles(t, dir, map[string]string{
+		"other.go": "// Copyright 2019 Someone Else\n" + header + "\npackage main\n",
+	})
+	if got := Code(run(context.Background(), []string{dir}, opts, buf)); got != exitSourceNeedsToBeRewritten {
+		t.Errorf("run() = %v with the copyright string of another licensor, want %v", got, exitSourceNeedsToBeRewritten)
+	}
+}
+
+func Test_isCopyrightLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{line: "// Copyright 2019 Elasticsearch B.V.", want: true},
+		{line: "// Copyright 2019-2024 Elasticsearch B.V.", want: true},
+		{line: "// Copyright Elasticsearch B.V.", want: false},
+		{line: "// Copyright 19 Elasticsearch B.V.", want: false},
+		{line: "// Copyright 2019- Elasticsearch B.V.", want: false},
+		{line: "// Copyright 2019 Elasticsearch B.V. and contributors", want: false},
+		{line: "// Copyright 2019 Someone Else", want: false},
+	}
+	for _, tt := range tests {
+		if got := isCopyrightLine(tt.line, defaultLicensor); got != tt.want {
+			t.Errorf("isCopyrightLine(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func Test_run_encoding(t *testing.T) {
+	header, err := renderHeader(options{license: defaultLicense, licensor: defaultLicensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var crlf = func(s string) string { return strings.ReplaceAll(s, "\n", "\r\n") }
+	const bom = "\xef\xbb\xbf"
+
+	var dir = t.TempDir()
+	var files = map[string]string{
+		"bom.go":   bom + string(header) + "\npackage main\n",
+		"crlf.go":  crlf(string(header) + "\npackage main\n"),
+		"fix.go":   bom + crlf("package main\n"),
+		"utf16.go": "\xff\xfep\x00a\x00c\x00k\x00a\x00g\x00e\x00",
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf = new(bytes.Buffer)
+	err = run(context.Background(), []string{dir}, options{
+		license:  defaultLicense,
+		licensor: defaultLicensor,
+		ext:      defaultExt,
+	}, buf)
+	if got := Code(err); got != errUnsupportedEncoding {
+		t.Errorf("run() = %v, want %v", got, errUnsupportedEncoding)
+	}
+	var want = "encoding: " + filepath.Join(dir, "utf16.go") + ": UTF-16 encoded files aren't supported\n"
+	if err == nil || err.Error() != want {
+		t.Errorf("run() = %v, want %q", err, want)
+	}
+
+	var wantFiles = map[string]string{
+		"bom.go":   files["bom.go"],
+		"crlf.go":  files["crlf.go"],
+		"fix.go":   bom + crlf(string(header)+"\npackage main\n"),
+		"utf16.go": files["utf16.go"],
+	}
+	for name, want := range wantFiles {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func Test_run_binary(t *testing.T) {
+	header, err := renderHeader(options{license: defaultLicense, licensor: defaultLicensor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The line is longer than the preamble, so the rewrite streams most of it.
+	var long = "package main\n\nvar data = \"" + strings.Repeat("x", 2*licensing.PreambleSize) + "\"\n"
+
+	var dir = t.TempDir()
+	var files = map[string]string{
+		"blob.go":   "package main\x00\x01\x02",
+		"latin1.go": "// caf\xe9\npackage main\n",
+		"long.go":   long,
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf = new(bytes.Buffer)
+	err = run(context.Background(), []string{dir}, options{
+		license:  defaultLicense,
+		licensor: defaultLicensor,
+		ext:      defaultExt,
+		pathBase: dir,
+	}, buf)
+	if err != nil {
+		t.Errorf("run() = %v, want nil", err)
+	}
+	var wantOutput = "blob.go: is skipped as a binary file, it contains NUL bytes\n" +
+		"latin1.go: is skipped as a binary file, it isn't valid UTF-8\n"
+	if got := buf.String(); got != wantOutput {
+		t.Errorf("Output = %q, want %q", got, wantOutput)
+	}
+
+	var wantFiles = map[string]string{
+		"blob.go":   files["blob.go"],
+		"latin1.go": files["latin1.go"],
+		"long.go":   string(header) + "\n" + long,
+	}
+	for name, want := range wantFiles {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %d bytes, want %d bytes", name, len(got), len(want))
+		}
+	}
+}
+
+func Test_runFS(t *testing.T) {
+	for _, license := range []string{defaultLicense, "ASL2-Short", "Cloud", "Elastic", "Elasticv2"} {
+		t.Run(license, func(t *testing.T) {
+			var fsys = fixturesFS(t, nil)
+			var buf = new(bytes.Buffer)
+			var err = runFS(context.Background(), fsys, options{
+				license:  license,
+				licensor: defaultLicensor,
+				exclude:  []string{"excludedpath"},
+				ext:      defaultExt,
+			}, buf)
+			if err != nil {
+				t.Fatalf("runFS() = %v, want nil", err)
+			}
+
+			// The tree is fixed in memory like the copy of the fixtures
+			// rewritten into the golden directory.
+			var golden = os.DirFS(filepath.Join("golden", license))
+			for name, f := range fsys.MapFS {
+				want, err := fs.ReadFile(golden, name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(f.Data) != string(want) {
+					t.Errorf("%s = \n%s\n want \n%s", name, f.Data, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_runFS_readOnly(t *testing.T) {
+	var fsys = fstest.MapFS{
+		"main.go":     {Data: []byte("package main\n")},
+		"sub/main.go": {Data: []byte("package sub\n")},
+	}
+
+	var buf = new(bytes.Buffer)
+	var opts = options{license: defaultLicense, licensor: defaultLicensor, ext: defaultExt, dry: true}
+	if got := Code(runFS(context.Background(), fsys, opts, buf)); got != exitSourceNeedsToBeRewritten {
+		t.Errorf("runFS() = %v, want %v", got, exitSourceNeedsToBeRewritten)
+	}
+	var wantOutput = filepath.FromSlash("main.go: is missing the license header\nsub/main.go: is missing the license header\n")
+	if got := buf.String(); got != wantOutput {
+		t.Errorf("Output = %q, want %q", got, wantOutput)
+	}
+
+	opts.dry = false
+	var err = runFS(context.Background(), fsys, opts, new(bytes.Buffer))
+	if got := Code(err); got != errFailedRewrittingFile {
+		t.Errorf("runFS() = %v, want %v", got, errFailedRewrittingFile)
+	}
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("runFS() = %v, want %v", err, errors.ErrUnsupported)
+	}
+	if got := string(fsys["main.go"].Data); got != "package main\n" {
+		t.Errorf("main.go = %q, want it untouched", got)
+	}
+}
@@ -0,0 +1,139 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+// licenseFileNames are the file names which are looked up in a module root
+// to determine its license, in order of preference.
+var licenseFileNames = []string{
+	"LICENSE", "LICENSE.txt", "LICENSE.md", "LICENCE", "LICENCE.txt", "LICENCE.md",
+	"COPYING", "COPYING.txt", "COPYING.md",
+}
+
+// errSourcesUnavailable is returned for the modules which aren't in the module
+// cache, since `go list -m` doesn't download them.
+var errSourcesUnavailable = errors.New("module sources not available, run go mod download")
+
+// module is a subset of the fields returned by `go list -m -json`.
+type module struct {
+	Path     string
+	Version  string
+	Dir      string
+	Main     bool
+	Indirect bool
+	Replace  *module
+}
+
+// listModules returns the build list of the module found in dir. It's a
+// variable so that it can be replaced in tests.
+var listModules = func(dir string) ([]module, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", "list", "-m", "-json", "all")
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("go list: %s", strings.TrimSpace(stderr.String()))
+		}
+		return nil, err
+	}
+
+	return decodeModules(bytes.NewReader(out))
+}
+
+// decodeModules decodes the stream of JSON objects printed by `go list -m -json`.
+func decodeModules(r io.Reader) ([]module, error) {
+	var modules []module
+	var dec = json.NewDecoder(r)
+	for {
+		var m module
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		modules = append(modules, m)
+	}
+
+	return modules, nil
+}
+
+// dependencies returns the main module found in dir and the rest of the
+// modules in its build list, with replacements resolved.
+func dependencies(dir string) (module, []module, error) {
+	modules, err := listModules(dir)
+	if err != nil {
+		return module{}, nil, err
+	}
+
+	var main module
+	var deps []module
+	for _, m := range modules {
+		if m.Main {
+			main = m
+			continue
+		}
+		if m.Replace != nil {
+			m.Dir = m.Replace.Dir
+			if m.Replace.Version != "" {
+				m.Version = m.Replace.Version
+			}
+		}
+		deps = append(deps, m)
+	}
+
+	return main, deps, nil
+}
+
+// moduleLicense reads the license file found in the module directory and
+// returns its SPDX identifier along with its contents. When no license file
+// can be found, licensing.Unknown is returned. When the module sources aren't
+// available, errSourcesUnavailable is returned.
+func moduleLicense(dir string) (string, []byte, error) {
+	if dir == "" {
+		return licensing.Unknown, nil, errSourcesUnavailable
+	}
+
+	for _, name := range licenseFileNames {
+		text, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return licensing.Unknown, nil, err
+		}
+		return licensing.Classify(text), text, nil
+	}
+
+	return licensing.Unknown, nil, nil
+}
@@ -0,0 +1,215 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+const defaultNoticeFile = "NOTICE.txt"
+
+var noticeUsageText = `
+Usage: go-licenser notice [flags] [path]
+
+  go-licenser notice generates the NOTICE file for the Go module found in path from its
+  dependencies' license files. With -check, the existing file is verified instead.
+
+Options:
+
+`[1:]
+
+// defaultNoticeTemplate is used when no template is passed to the notice
+// command.
+var defaultNoticeTemplate = `
+{{ .Project }}
+Copyright {{ .Licensor }}
+
+This product includes software developed by {{ .Licensor }}
+and is licensed under {{ .License }}.
+
+{{- if .Dependencies }}
+
+=========================================================================
+Third party libraries used by {{ .Project }}:
+=========================================================================
+{{- range .Dependencies }}
+
+-------------------------------------------------------------------------
+Dependency : {{ .Path }}
+Version    : {{ .Version }}
+License    : {{ .License }}
+{{- if .Text }}
+
+{{ .Text }}
+{{- end }}
+{{- end }}
+{{- end }}
+`[1:]
+
+// permissiveLicenses are the SPDX identifiers of the licenses which don't
+// require their full text to be reproduced in the NOTICE file.
+var permissiveLicenses = []string{
+	"Apache-2.0", "MIT", "BSD-2-Clause", "BSD-3-Clause", "ISC", "BSL-1.0",
+	"CC0-1.0", "Unlicense",
+}
+
+type noticeOptions struct {
+	path     string
+	output   string
+	template string
+	license  string
+	licensor string
+	check    bool
+}
+
+type noticeData struct {
+	Project      string
+	Licensor     string
+	License      string
+	Dependencies []noticeDependency
+}
+
+type noticeDependency struct {
+	Path    string
+	Version string
+	License string
+	Text    string
+}
+
+// noticeCommand parses the notice flags from args and runs the command.
+func noticeCommand(args []string, out io.Writer) error {
+	var opts noticeOptions
+	var fs = flag.NewFlagSet("notice", flag.ExitOnError)
+	fs.BoolVar(&opts.check, "check", false, `skips writing the file and returns exitcode 8 if it is out of date.`)
+	fs.StringVar(&opts.output, "o", "", fmt.Sprintf("sets the output file (default \"<path>/%s\").", defaultNoticeFile))
+	fs.StringVar(&opts.template, "template", "", "sets the text/template file used to render the NOTICE file.")
+	fs.StringVar(&opts.license, "license", defaultLicense, "sets the license type of the project.")
+	fs.StringVar(&opts.licensor, "licensor", defaultLicensor, "sets the name of the licensor")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), noticeUsageText)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	opts.path = defaultPath
+	if fs.NArg() > 0 {
+		opts.path = fs.Arg(0)
+	}
+
+	return notice(opts, out)
+}
+
+func notice(opts noticeOptions, out io.Writer) error {
+	spdx, ok := licensing.SPDX[opts.license]
+	if !ok {
+		return &Error{err: fmt.Errorf("unknown license: %s", opts.license), code: errUnknownLicense}
+	}
+
+	if opts.output == "" {
+		opts.output = filepath.Join(opts.path, defaultNoticeFile)
+	}
+
+	var text = defaultNoticeTemplate
+	if opts.template != "" {
+		b, err := os.ReadFile(opts.template)
+		if err != nil {
+			return &Error{err: err, code: errFailedGeneratingNotice}
+		}
+		text = string(b)
+	}
+
+	tmpl, err := template.New("notice").Parse(text)
+	if err != nil {
+		return &Error{err: err, code: errFailedGeneratingNotice}
+	}
+
+	data, err := noticeDataFor(opts.path, opts.licensor, spdx)
+	if errors.Is(err, errSourcesUnavailable) {
+		return &Error{err: err, code: errModuleSourcesUnavailable}
+	}
+	if err != nil {
+		return &Error{err: err, code: errFailedToListModules}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return &Error{err: err, code: errFailedGeneratingNotice}
+	}
+
+	if opts.check {
+		current, err := os.ReadFile(opts.output)
+		if err != nil && !os.IsNotExist(err) {
+			return &Error{err: err, code: errFailedGeneratingNotice}
+		}
+		if !bytes.Equal(current, buf.Bytes()) {
+			fmt.Fprintf(out, "%s: is out of date\n", opts.output)
+			return &Error{code: exitNoticeNeedsToBeRewritten}
+		}
+		return nil
+	}
+
+	if err := os.WriteFile(opts.output, buf.Bytes(), 0644); err != nil {
+		return &Error{err: err, code: errFailedGeneratingNotice}
+	}
+
+	return nil
+}
+
+// noticeDataFor collects the license information of the dependencies of the
+// module found in path.
+func noticeDataFor(path, licensor, spdx string) (noticeData, error) {
+	root, deps, err := dependencies(path)
+	if err != nil {
+		return noticeData{}, err
+	}
+
+	var data = noticeData{
+		Project:  root.Path,
+		Licensor: licensor,
+		License:  spdx,
+	}
+	if data.Project == "" {
+		abs, _ := filepath.Abs(path)
+		data.Project = filepath.Base(abs)
+	}
+
+	for _, m := range deps {
+		id, text, err := moduleLicense(m.Dir)
+		if err != nil {
+			return noticeData{}, fmt.Errorf("%s@%s: %w", m.Path, m.Version, err)
+		}
+
+		var dep = noticeDependency{Path: m.Path, Version: m.Version, License: id}
+		if !stringInSlice(id, permissiveLicenses) {
+			dep.Text = strings.TrimSpace(string(text))
+		}
+		data.Dependencies = append(data.Dependencies, dep)
+	}
+
+	return data, nil
+}
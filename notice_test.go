@@ -0,0 +1,181 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testMPLText = `Mozilla Public License Version 2.0
+==================================`
+
+func stubModules(t *testing.T) func() {
+	var dir = t.TempDir()
+	var mit = filepath.Join(dir, "mit")
+	var mpl = filepath.Join(dir, "mpl")
+	var missing = filepath.Join(dir, "missing")
+	if err := os.MkdirAll(missing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for p, text := range map[string]string{
+		mit: "Permission is hereby granted, free of charge, to any person obtaining a copy",
+		mpl: testMPLText,
+	} {
+		if err := os.MkdirAll(p, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(p, "LICENSE"), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var orig = listModules
+	listModules = func(string) ([]module, error) {
+		return []module{
+			{Path: "github.com/elastic/example", Main: true},
+			{Path: "github.com/mit/dep", Version: "v1.0.0", Dir: mit},
+			{Path: "github.com/mpl/dep", Version: "v0.1.0", Dir: mpl, Indirect: true},
+			{Path: "github.com/missing/dep", Version: "v2.0.0", Dir: missing},
+		}, nil
+	}
+	return func() { listModules = orig }
+}
+
+func Test_notice(t *testing.T) {
+	defer stubModules(t)()
+
+	var want = `
+github.com/elastic/example
+Copyright Elasticsearch B.V.
+
+This product includes software developed by Elasticsearch B.V.
+and is licensed under Apache-2.0.
+
+=========================================================================
+Third party libraries used by github.com/elastic/example:
+=========================================================================
+
+-------------------------------------------------------------------------
+Dependency : github.com/mit/dep
+Version    : v1.0.0
+License    : MIT
+
+-------------------------------------------------------------------------
+Dependency : github.com/mpl/dep
+Version    : v0.1.0
+License    : MPL-2.0
+
+Mozilla Public License Version 2.0
+==================================
+
+-------------------------------------------------------------------------
+Dependency : github.com/missing/dep
+Version    : v2.0.0
+License    : UNKNOWN
+`[1:]
+
+	var dir = t.TempDir()
+	var output = filepath.Join(dir, defaultNoticeFile)
+	var opts = noticeOptions{
+		path:     dir,
+		license:  defaultLicense,
+		licensor: defaultLicensor,
+		check:    true,
+	}
+
+	var buf = new(bytes.Buffer)
+	err := notice(opts, buf)
+	if !reflect.DeepEqual(err, &Error{code: exitNoticeNeedsToBeRewritten}) {
+		t.Fatalf("notice() error = %v, want a stale NOTICE error", err)
+	}
+	if got := buf.String(); got != output+": is out of date\n" {
+		t.Errorf("notice() output = %q", got)
+	}
+
+	opts.check = false
+	if err := notice(opts, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("notice() = \n%s\n want \n%s", got, want)
+	}
+
+	buf.Reset()
+	opts.check = true
+	if err := notice(opts, buf); err != nil {
+		t.Errorf("notice() error = %v on an up to date file", err)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("notice() output = %q on an up to date file", buf.String())
+	}
+}
+
+func Test_notice_errors(t *testing.T) {
+	defer stubModules(t)()
+
+	tests := []struct {
+		name string
+		opts noticeOptions
+		want int
+	}{
+		{
+			name: "Unknown license fails",
+			opts: noticeOptions{license: "foo"},
+			want: errUnknownLicense,
+		},
+		{
+			name: "Missing template fails",
+			opts: noticeOptions{license: defaultLicense, template: "unexisting"},
+			want: errFailedGeneratingNotice,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.path = t.TempDir()
+			if got := Code(notice(tt.opts, new(bytes.Buffer))); got != tt.want {
+				t.Errorf("notice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var orig = listModules
+	defer func() { listModules = orig }()
+	listModules = func(string) ([]module, error) { return nil, errors.New("no go.mod") }
+	var err = notice(noticeOptions{path: t.TempDir(), license: defaultLicense}, new(bytes.Buffer))
+	if !reflect.DeepEqual(err, &Error{err: errors.New("no go.mod"), code: errFailedToListModules}) {
+		t.Errorf("notice() error = %v", err)
+	}
+
+	listModules = func(string) ([]module, error) {
+		return []module{{Path: "github.com/example/dep", Version: "v1.0.0"}}, nil
+	}
+	err = notice(noticeOptions{path: t.TempDir(), license: defaultLicense}, new(bytes.Buffer))
+	if got := Code(err); got != errModuleSourcesUnavailable {
+		t.Errorf("notice() = %v, want %v (%v)", got, errModuleSourcesUnavailable, err)
+	}
+}
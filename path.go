@@ -19,13 +19,24 @@ package main
 
 import (
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
 )
 
+// needsExclusion returns true when the path is any of the exclude patterns or
+// is inside one of them, matching whole path components only, so that "gen"
+// doesn't exclude "generated". Both are compared with forward slashes, and the
+// trailing "*" and separators of the patterns are ignored. The patterns which
+// are left empty, like ".", exclude nothing.
 func needsExclusion(path string, exclude []string) bool {
+	path = filepath.ToSlash(path)
 	for _, excluded := range exclude {
-		excluded = cleanPathSuffixes(excluded, []string{"*", string(os.PathSeparator)})
-		if strings.HasPrefix(path, excluded) {
+		var pattern = cleanPattern(excluded)
+		if pattern == "" {
+			continue
+		}
+		if path == pattern || strings.HasPrefix(path, pattern+"/") {
 			return true
 		}
 	}
@@ -33,6 +44,20 @@ func needsExclusion(path string, exclude []string) bool {
 	return false
 }
 
+// cleanPattern normalises an exclude pattern so that "./vendor/", "vendor/*"
+// and "vendor" are equivalent.
+func cleanPattern(pattern string) string {
+	pattern = cleanPathSuffixes(filepath.ToSlash(pattern), []string{"*", "/"})
+	if pattern == "" {
+		return pattern
+	}
+
+	if pattern = path.Clean(pattern); pattern == "." {
+		return ""
+	}
+	return pattern
+}
+
 func cleanPathSuffixes(path string, sufixes []string) string {
 	for _, suffix := range sufixes {
 		for strings.HasSuffix(path, suffix) && len(path) > 0 {
@@ -43,12 +68,60 @@ func cleanPathSuffixes(path string, sufixes []string) string {
 	return path
 }
 
-func cleanPathPrefixes(path string, prefixes []string) string {
-	for _, prefix := range prefixes {
-		for strings.HasPrefix(path, prefix) && len(path) > 0 {
-			path = path[len(prefix):]
+// exclusions evaluates the exclude patterns against the paths found in a
+// root. The patterns can be relative to the root, relative to the working
+// directory or absolute.
+type exclusions struct {
+	root     string
+	cwd      string
+	patterns []string
+}
+
+func newExclusions(root string, exclude []string) exclusions {
+	var e = exclusions{patterns: exclude}
+	e.root, _ = filepath.Abs(root)
+	e.cwd, _ = os.Getwd()
+	return e
+}
+
+// match returns true when the path is matched by any of the patterns. The
+// root itself is never matched.
+func (e exclusions) match(p string) bool {
+	abs, err := filepath.Abs(p)
+	if err != nil || abs == e.root || len(e.patterns) == 0 {
+		return false
+	}
+
+	for _, base := range []string{e.root, e.cwd} {
+		if base == "" {
+			continue
+		}
+		if rel, err := filepath.Rel(base, abs); err == nil && needsExclusion(rel, e.patterns) {
+			return true
 		}
 	}
 
-	return path
+	for _, pattern := range e.patterns {
+		if filepath.IsAbs(pattern) && needsExclusion(abs, []string{pattern}) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcluded returns true when the path is excluded from the root, either
+// explicitly or because it's inside one of the default excluded directories.
+func isExcluded(root, p string, exclude []string) bool {
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == "." {
+		return false
+	}
+
+	for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+		if stringInSlice(part, defaultExludedDirs) {
+			return true
+		}
+	}
+
+	return newExclusions(root, exclude).match(p)
 }
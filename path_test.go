@@ -18,6 +18,8 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"path/filepath"
 	"testing"
 )
@@ -164,60 +166,73 @@ func Test_cleanPathSuffixes(t *testing.T) {
 	}
 }
 
-func Test_cleanPathPrefixes(t *testing.T) {
-	type args struct {
-		path     string
-		prefixes []string
+func Test_exclusions(t *testing.T) {
+	tmp, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
 	}
+	chdir(t, tmp)
+
+	var file = filepath.Join("src", "scripts", "gen", "a.go")
 	tests := []struct {
-		name string
-		args args
-		want string
+		name    string
+		root    string
+		path    string
+		exclude []string
+		want    bool
 	}{
-		{
-			name: "Cleans path prefixes",
-			args: args{
-				path:     "/prefix",
-				prefixes: []string{"/"},
-			},
-			want: "prefix",
-		},
-		{
-			name: "Cleans no path prefixes",
-			args: args{
-				path:     "prefix",
-				prefixes: []string{"/"},
-			},
-			want: "prefix",
-		},
-		{
-			name: "Cleans path prefixes",
-			args: args{
-				path:     "xyzprefix",
-				prefixes: []string{"xyz"},
-			},
-			want: "prefix",
-		},
-		{
-			name: "Cleans no prefixes (Empty prefixes)",
-			args: args{
-				path: "something",
-			},
-			want: "something",
-		},
-		{
-			name: "Cleans no prefixes (Empty path)",
-			args: args{
-				path:     "",
-				prefixes: []string{"zyx"},
-			},
-			want: "",
-		},
+		{name: "Dot root", root: ".", path: file, exclude: []string{"src/scripts"}, want: true},
+		{name: "Dot slash root", root: "./", path: file, exclude: []string{"src/scripts"}, want: true},
+		{name: "Absolute root", root: tmp, path: filepath.Join(tmp, file), exclude: []string{"src/scripts"}, want: true},
+		{name: "Nested root relative to the root", root: "src", path: file, exclude: []string{"scripts"}, want: true},
+		{name: "Nested root relative to the working directory", root: "src", path: file, exclude: []string{"src/scripts/gen"}, want: true},
+		{name: "Nested dot slash root", root: "./src/", path: "./" + file, exclude: []string{"scripts/*"}, want: true},
+		{name: "Nested absolute root", root: filepath.Join(tmp, "src"), path: filepath.Join(tmp, file), exclude: []string{"./scripts/"}, want: true},
+		{name: "Absolute pattern", root: "src", path: file, exclude: []string{filepath.Join(tmp, "src", "scripts")}, want: true},
+		{name: "Characters of the root aren't trimmed", root: "./src", path: file, exclude: []string{"ipts"}, want: false},
+		{name: "Other directory", root: "src", path: file, exclude: []string{"gen"}, want: false},
+		{name: "Prefix of a directory name", root: ".", path: file, exclude: []string{"src/scr"}, want: false},
+		{name: "Prefix of a file name", root: ".", path: filepath.Join("src", "generated", "b.go"), exclude: []string{"src/gen"}, want: false},
+		{name: "Prefix of a nested directory name", root: "src", path: file, exclude: []string{"script"}, want: false},
+		{name: "Dot pattern", root: ".", path: file, exclude: []string{"."}, want: false},
+		{name: "Dot slash star pattern", root: ".", path: file, exclude: []string{"./*"}, want: false},
+		{name: "File pattern", root: ".", path: file, exclude: []string{"src/scripts/gen/a.go"}, want: true},
+		{name: "Root is never excluded", root: "src", path: "src", exclude: []string{"src"}, want: false},
+		{name: "No patterns", root: ".", path: file, want: false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := cleanPathPrefixes(tt.args.path, tt.args.prefixes); got != tt.want {
-				t.Errorf("cleanPathPrefixes() = %v, want %v", got, tt.want)
+			if got := newExclusions(tt.root, tt.exclude).match(tt.path); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_run_exclusions(t *testing.T) {
+	tmp, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, tmp, map[string]string{
+		"src/scripts/a.go": "package main\n",
+		"src/rcs/b.go":     "package main\n",
+		"src/main.go":      "package main\n",
+	})
+	chdir(t, tmp)
+
+	for _, root := range []string{"./src", "src/", filepath.Join(tmp, "src")} {
+		t.Run(root, func(t *testing.T) {
+			var buf = new(bytes.Buffer)
+			run(context.Background(), []string{root}, options{
+				license:  defaultLicense,
+				licensor: defaultLicensor,
+				exclude:  []string{"scripts", "src/rcs", "main.go"},
+				ext:      defaultExt,
+				dry:      true,
+			}, buf)
+			if got := buf.String(); got != "" {
+				t.Errorf("Output = %q, want all the files to be excluded", got)
 			}
 		})
 	}
@@ -0,0 +1,207 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+var reportFormats = []string{formatText, formatJSON}
+
+// Finding kinds.
+const (
+	findingMissingHeader      = "missing-header"
+	findingOutdatedHeader     = "outdated-header"
+	findingPackageDoc         = "package-doc-header"
+	findingMisplaced          = "misplaced-header"
+	findingDuplicateHeader    = "duplicate-header"
+	findingBinary             = "binary-file"
+	findingDeniedLicense      = "denied-license"
+	findingUnknownLicense     = "unknown-license"
+	findingReviewLicense      = "review-required"
+	findingUnavailableSources = "unavailable-sources"
+
+	findingLicenseFileMismatch = "license-file-mismatch"
+	findingMissingLicenseFile  = "missing-license-file"
+
+	findingOptOut           = "opt-out"
+	findingInvalidDirective = "invalid-directive"
+	findingStaleDirective   = "stale-directive"
+
+	findingForeignCopyright = "foreign-copyright"
+)
+
+// finding is a single discrepancy found while checking a tree.
+type finding struct {
+	Path    string `json:"path"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// reporter outputs the findings in a specific format. Flush must be called
+// once all the findings, and optionally the summary, have been reported.
+type reporter interface {
+	Report(f finding)
+	Summarize(s *summary)
+	Flush() error
+}
+
+// newReporter returns the reporter for the format, or an error when the format
+// isn't supported.
+func newReporter(format string, out io.Writer) (reporter, error) {
+	switch format {
+	case formatText, "":
+		return &textReporter{out: out}, nil
+	case formatJSON:
+		return &jsonReporter{out: out}, nil
+	}
+	return nil, fmt.Errorf("unknown format: %s", format)
+}
+
+// textReporter prints each finding on its own line as soon as it's reported.
+type textReporter struct {
+	out      io.Writer
+	reported bool
+}
+
+func (r *textReporter) Report(f finding) {
+	fmt.Fprintf(r.out, "%s: %s\n", f.Path, f.Message)
+	r.reported = true
+}
+
+// Summarize prints the summary, separated from the findings by a blank line.
+func (r *textReporter) Summarize(s *summary) {
+	if r.reported {
+		fmt.Fprintln(r.out)
+	}
+	writeSummary(r.out, s)
+}
+
+func (r *textReporter) Flush() error { return nil }
+
+// jsonReporter collects all the findings and prints them as a single JSON
+// document when flushed.
+type jsonReporter struct {
+	out      io.Writer
+	findings []finding
+	summary  *summary
+}
+
+func (r *jsonReporter) Report(f finding) {
+	r.findings = append(r.findings, f)
+}
+
+func (r *jsonReporter) Summarize(s *summary) {
+	r.summary = s
+}
+
+func (r *jsonReporter) Flush() error {
+	var doc = struct {
+		Findings []finding `json:"findings"`
+		Summary  *summary  `json:"summary,omitempty"`
+	}{Findings: r.findings, Summary: r.summary}
+	if doc.Findings == nil {
+		doc.Findings = []finding{}
+	}
+
+	var enc = json.NewEncoder(r.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// pathReporter rewrites the paths of the findings to be relative to a base
+// directory, or absolute, before passing them to the wrapped reporter.
+type pathReporter struct {
+	reporter
+	base  string
+	abs   bool
+	trees []tree
+}
+
+// newPathReporter wraps r so that the reported paths are relative to base,
+// which defaults to the working directory, or absolute when abs is true. The
+// paths of the files of the trees of an archive or a git revision, reported as
+// <base><sep><name>, only have their base rewritten when it's a path.
+func newPathReporter(r reporter, base string, abs bool, trees ...tree) (reporter, error) {
+	var err error
+	if base == "" {
+		base, err = os.Getwd()
+	} else {
+		base, err = filepath.Abs(base)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pathReporter{reporter: r, base: base, abs: abs, trees: trees}, nil
+}
+
+func (r *pathReporter) Report(f finding) {
+	f.Path = r.display(f.Path)
+	r.reporter.Report(f)
+}
+
+// display returns the path as it's reported. Only the path of the archive is
+// rewritten in the paths of its files, and the paths of the files of a git
+// revision, whose base is the revision, are left as they are.
+func (r *pathReporter) display(path string) string {
+	for _, t := range r.trees {
+		if t.base == "" {
+			continue
+		}
+		name, inTree := strings.CutPrefix(path, t.base+t.sep)
+		switch {
+		case !inTree && path != t.base:
+			continue
+		case t.sep == revSep:
+			return path
+		case !inTree:
+			return displayPath(r.base, t.base, r.abs)
+		}
+		return displayPath(r.base, t.base, r.abs) + t.sep + name
+	}
+	return displayPath(r.base, path, r.abs)
+}
+
+// displayPath returns path relative to base, or absolute when abs is true or
+// it can't be made relative to base.
+func displayPath(base, path string, abs bool) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if abs {
+		return absPath
+	}
+
+	rel, err := filepath.Rel(base, absPath)
+	if err != nil {
+		return absPath
+	}
+	return rel
+}
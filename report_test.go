@@ -0,0 +1,132 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdir changes the working directory to dir until the test ends.
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func Test_run_pathBase(t *testing.T) {
+	tmp, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"src/pkg", "other"} {
+		if err := os.MkdirAll(filepath.Join(tmp, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "src", "pkg", "a.go"), []byte("package pkg\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		wd       string
+		path     string
+		pathBase string
+		abs      bool
+		want     string
+	}{
+		{name: "Relative root from the parent", wd: ".", path: "src", want: "src/pkg/a.go"},
+		{name: "Dot root", wd: "src", path: ".", want: "pkg/a.go"},
+		{name: "Dot slash root", wd: "src", path: "./", want: "pkg/a.go"},
+		{name: "Root in a sibling directory", wd: "other", path: "../src", want: "../src/pkg/a.go"},
+		{name: "Absolute root", wd: "other", path: filepath.Join(tmp, "src"), want: "../src/pkg/a.go"},
+		{name: "Path base", wd: ".", path: "src", pathBase: "src/pkg", want: "a.go"},
+		{name: "Absolute paths", wd: "other", path: "../src", abs: true, want: filepath.Join(tmp, "src", "pkg", "a.go")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdir(t, filepath.Join(tmp, tt.wd))
+
+			var buf = new(bytes.Buffer)
+			var err = run(context.Background(), []string{tt.path}, options{
+				license:  defaultLicense,
+				licensor: defaultLicensor,
+				ext:      defaultExt,
+				dry:      true,
+				pathBase: tt.pathBase,
+				absPaths: tt.abs,
+			}, buf)
+			if got := Code(err); got != exitSourceNeedsToBeRewritten {
+				t.Errorf("run() = %v, want %v", got, exitSourceNeedsToBeRewritten)
+			}
+
+			var want = filepath.FromSlash(tt.want) + ": is missing the license header\n"
+			if got := buf.String(); got != want {
+				t.Errorf("Output = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func Test_pathReporter_display(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive = tree{root: ".", base: filepath.Join("testdata", "mod.zip"), sep: archiveSep}
+	var rev = tree{root: ".", base: "HEAD~1", sep: revSep}
+
+	tests := []struct {
+		name     string
+		pathBase string
+		abs      bool
+		path     string
+		want     string
+	}{
+		{name: "File", pathBase: "testdata", path: filepath.Join("testdata", "a.go"), want: "a.go"},
+		{name: "Archive file", pathBase: "testdata", path: archive.path("../a.go"), want: "mod.zip!../a.go"},
+		{name: "Archive", pathBase: "testdata", path: archive.base, want: "mod.zip"},
+		{name: "Absolute archive file", abs: true, path: archive.path("a.go"), want: filepath.Join(wd, "testdata", "mod.zip") + "!a.go"},
+		{name: "Revision file", pathBase: "testdata", path: rev.path("a.go"), want: "HEAD~1:a.go"},
+		{name: "Absolute revision file", abs: true, path: rev.path("a.go"), want: "HEAD~1:a.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newPathReporter(nil, tt.pathBase, tt.abs, archive, rev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.(*pathReporter).display(tt.path); got != tt.want {
+				t.Errorf("display() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
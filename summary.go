@@ -0,0 +1,209 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Outcomes of checking a file.
+const (
+	statusCompliant = "compliant"
+	statusFixed     = "fixed"
+	statusFailing   = "failing"
+	statusOptedOut  = "opted-out"
+	statusSkipped   = "skipped"
+)
+
+// counts are the number of files per outcome.
+type counts struct {
+	Compliant int     `json:"compliant"`
+	Fixed     int     `json:"fixed"`
+	Failing   int     `json:"failing"`
+	OptedOut  int     `json:"opted_out"`
+	Skipped   int     `json:"skipped"`
+	Coverage  float64 `json:"coverage"`
+}
+
+func (c *counts) add(status string) {
+	switch status {
+	case statusCompliant:
+		c.Compliant++
+	case statusFixed:
+		c.Fixed++
+	case statusFailing:
+		c.Failing++
+	case statusOptedOut:
+		c.OptedOut++
+	case statusSkipped:
+		c.Skipped++
+	}
+
+	// The coverage is the percentage of the files which aren't opted out or
+	// skipped that carry the header.
+	var covered, total = c.Compliant + c.Fixed, c.Compliant + c.Fixed + c.Failing
+	c.Coverage = 100
+	if total > 0 {
+		c.Coverage = float64(covered*1000/total) / 10
+	}
+}
+
+func (c counts) files() int {
+	return c.Compliant + c.Fixed + c.Failing + c.OptedOut + c.Skipped
+}
+
+// timings are the durations of the run, in milliseconds.
+type timings struct {
+	Walk  float64 `json:"walk_ms"`
+	Total float64 `json:"total_ms"`
+}
+
+// summary collects the outcome of every file checked in a run. Its methods
+// can be called on a nil summary, which collects nothing.
+type summary struct {
+	root string
+
+	Scanned     int                `json:"scanned"`
+	Excluded    int                `json:"excluded"`
+	Outcomes    counts             `json:"outcomes"`
+	Licenses    map[string]*counts `json:"licenses"`
+	Directories map[string]*counts `json:"directories"`
+	Timings     timings            `json:"timings"`
+}
+
+func newSummary(root string) *summary {
+	return &summary{
+		root:        root,
+		Licenses:    make(map[string]*counts),
+		Directories: make(map[string]*counts),
+	}
+}
+
+// exclude records a path skipped because of the exclusions.
+func (s *summary) exclude() {
+	if s != nil {
+		s.Excluded++
+	}
+}
+
+// record adds the outcome of checking the file found in path against the
+// license. An error always makes the file count as failing. Files which opted
+// out of any license are recorded without one.
+func (s *summary) record(path, license, status string, err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		status = statusFailing
+	}
+
+	s.Scanned++
+	s.Outcomes.add(status)
+
+	if license != "" {
+		if s.Licenses[license] == nil {
+			s.Licenses[license] = new(counts)
+		}
+		s.Licenses[license].add(status)
+	}
+
+	var dir = topLevelDir(s.root, path)
+	if s.Directories[dir] == nil {
+		s.Directories[dir] = new(counts)
+	}
+	s.Directories[dir].add(status)
+}
+
+// done records the durations of the run.
+func (s *summary) done(start time.Time, walk time.Duration) {
+	if s == nil {
+		return
+	}
+	s.Timings = timings{
+		Walk:  float64(walk.Microseconds()) / 1000,
+		Total: float64(time.Since(start).Microseconds()) / 1000,
+	}
+}
+
+// topLevelDir returns the first directory of path relative to the root, or
+// "." for the files found directly in the root.
+func topLevelDir(root, path string) string {
+	// The files found at the root of an archive or a revision are reported
+	// as archive!name and rev:name.
+	for _, sep := range []string{archiveSep, revSep} {
+		if name, ok := strings.CutPrefix(path, root+sep); ok {
+			root, path = ".", filepath.FromSlash(name)
+		}
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "."
+	}
+	if parts := strings.SplitN(filepath.ToSlash(rel), "/", 2); len(parts) == 2 {
+		return parts[0]
+	}
+	return "."
+}
+
+// writeSummary prints the summary as aligned text blocks.
+func writeSummary(out io.Writer, s *summary) error {
+	var w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Summary:\n")
+	fmt.Fprintf(w, "  scanned\t%d files\n", s.Scanned)
+	fmt.Fprintf(w, "  excluded\t%d paths\n", s.Excluded)
+	fmt.Fprintf(w, "  compliant\t%d\n", s.Outcomes.Compliant)
+	fmt.Fprintf(w, "  fixed\t%d\n", s.Outcomes.Fixed)
+	fmt.Fprintf(w, "  failing\t%d\n", s.Outcomes.Failing)
+	fmt.Fprintf(w, "  opted out\t%d\n", s.Outcomes.OptedOut)
+	fmt.Fprintf(w, "  skipped\t%d\n", s.Outcomes.Skipped)
+	fmt.Fprintf(w, "  coverage\t%.1f%%\n", s.Outcomes.Coverage)
+	fmt.Fprintf(w, "  duration\t%.1fms (walk %.1fms)\n", s.Timings.Total, s.Timings.Walk)
+
+	for _, block := range []struct {
+		title  string
+		counts map[string]*counts
+	}{
+		{title: "Licenses", counts: s.Licenses},
+		{title: "Directories", counts: s.Directories},
+	} {
+		if len(block.counts) == 0 {
+			continue
+		}
+
+		var keys = make([]string, 0, len(block.counts))
+		for k := range block.counts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(w, "\n%s:\n", block.title)
+		for _, k := range keys {
+			var c = block.counts[k]
+			fmt.Fprintf(w, "  %s\t%d files\t%.1f%% coverage\n", k, c.files(), c.Coverage)
+		}
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,158 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_run_summary(t *testing.T) {
+	tests := []struct {
+		name string
+		dry  bool
+		want summary
+	}{
+		{
+			name: "Dry run counts the failing files",
+			dry:  true,
+			want: summary{
+				Scanned:  19,
+				Excluded: 2,
+				Outcomes: counts{Compliant: 4, Failing: 14, OptedOut: 1, Coverage: 22.2},
+				Licenses: map[string]*counts{
+					"ASL2": {Compliant: 4, Failing: 14, Coverage: 22.2},
+				},
+				Directories: map[string]*counts{
+					".":           {Failing: 1, OptedOut: 1, Coverage: 0},
+					"cloud":       {Compliant: 1, Failing: 1, Coverage: 50},
+					"misplaced":   {Failing: 2, Coverage: 0},
+					"multilevel":  {Failing: 5, Coverage: 0},
+					"singlelevel": {Compliant: 1, Failing: 3, Coverage: 25},
+					"x-pack":      {Compliant: 1, Failing: 1, Coverage: 50},
+					"x-pack-v2":   {Compliant: 1, Failing: 1, Coverage: 50},
+				},
+			},
+		},
+		{
+			name: "Fix counts the fixed files",
+			want: summary{
+				Scanned:  19,
+				Excluded: 2,
+				Outcomes: counts{Compliant: 4, Fixed: 14, OptedOut: 1, Coverage: 100},
+				Licenses: map[string]*counts{
+					"ASL2": {Compliant: 4, Fixed: 14, Coverage: 100},
+				},
+				Directories: map[string]*counts{
+					".":           {Fixed: 1, OptedOut: 1, Coverage: 100},
+					"cloud":       {Compliant: 1, Fixed: 1, Coverage: 100},
+					"misplaced":   {Fixed: 2, Coverage: 100},
+					"multilevel":  {Fixed: 5, Coverage: 100},
+					"singlelevel": {Compliant: 1, Fixed: 3, Coverage: 100},
+					"x-pack":      {Compliant: 1, Fixed: 1, Coverage: 100},
+					"x-pack-v2":   {Compliant: 1, Fixed: 1, Coverage: 100},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fsys = fixturesFS(t, map[string]string{
+				"root.go":    "package main\n",
+				"ignored.go": "// go-licenser:ignore\npackage main\n",
+			})
+
+			var buf = new(bytes.Buffer)
+			runFS(context.Background(), fsys, options{
+				license:  defaultLicense,
+				licensor: defaultLicensor,
+				exclude:  []string{"excludedpath"},
+				ext:      defaultExt,
+				dry:      tt.dry,
+				format:   formatJSON,
+				summary:  true,
+			}, buf)
+
+			var doc struct {
+				Summary summary `json:"summary"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			}
+
+			var got = doc.Summary
+			if got.Timings.Total < got.Timings.Walk {
+				t.Errorf("Timings = %+v, the total is shorter than the walk", got.Timings)
+			}
+			got.Timings = timings{}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("summary = \n%+v\n want \n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeSummary(t *testing.T) {
+	var s = newSummary("root")
+	s.exclude()
+	s.record(filepath.Join("root", "main.go"), "ASL2", statusCompliant, nil)
+	s.record(filepath.Join("root", "x-pack", "a", "main.go"), "Elastic", statusFixed, nil)
+	s.record(filepath.Join("root", "x-pack", "b.go"), "Elastic", statusCompliant, &Error{code: exitSourceNeedsToBeRewritten})
+	s.record(filepath.Join("root", "third_party", "c.go"), "", statusOptedOut, nil)
+	s.record(filepath.Join("root", "third_party", "d.go"), "", statusSkipped, nil)
+	s.Timings = timings{Walk: 1.5, Total: 2}
+
+	var want = `
+Summary:
+  scanned    5 files
+  excluded   1 paths
+  compliant  1
+  fixed      1
+  failing    1
+  opted out  1
+  skipped    1
+  coverage   66.6%
+  duration   2.0ms (walk 1.5ms)
+
+Licenses:
+  ASL2     1 files  100.0% coverage
+  Elastic  2 files  50.0% coverage
+
+Directories:
+  .            1 files  100.0% coverage
+  third_party  2 files  100.0% coverage
+  x-pack       2 files  50.0% coverage
+`[1:]
+
+	var buf = new(bytes.Buffer)
+	if err := writeSummary(buf, s); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("writeSummary() = \n%s\n want \n%s", got, want)
+	}
+
+	// A nil summary collects nothing.
+	var nilSummary *summary
+	nilSummary.exclude()
+	nilSummary.record("main.go", "ASL2", statusFixed, nil)
+}
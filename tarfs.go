@@ -0,0 +1,181 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tarFS is the read-only fs.FS of an uncompressed tar archive, whose files
+// are read in place from the archive rather than held in memory. Only the
+// directories and regular files of the archive are part of it.
+type tarFS struct {
+	r     io.ReaderAt
+	files map[string]*tarEntry
+}
+
+// tarEntry is a file or directory of a tarFS, which is its own fs.FileInfo.
+type tarEntry struct {
+	name    string
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+
+	// offset is the position of the contents of the file in the archive.
+	offset int64
+	// entries are the files of the directory, sorted by name.
+	entries []fs.DirEntry
+}
+
+func (e *tarEntry) Name() string       { return e.name }
+func (e *tarEntry) Size() int64        { return e.size }
+func (e *tarEntry) Mode() fs.FileMode  { return e.mode }
+func (e *tarEntry) ModTime() time.Time { return e.modTime }
+func (e *tarEntry) IsDir() bool        { return e.mode.IsDir() }
+func (e *tarEntry) Sys() interface{}   { return nil }
+
+// newTarFS indexes the tar archive read from r, which is read sequentially
+// and must be positioned at its start.
+func newTarFS(r io.ReadSeeker) (*tarFS, error) {
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		return nil, errors.New("tar: the archive can't be read in place")
+	}
+
+	var t = tarFS{r: ra, files: map[string]*tarEntry{
+		".": {name: ".", mode: fs.ModeDir | 0755},
+	}}
+	var tr = tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var name = path.Clean(strings.TrimLeft(hdr.Name, "/"))
+		if name == "." || !fs.ValidPath(name) {
+			continue
+		}
+
+		var e = tarEntry{name: path.Base(name), mode: hdr.FileInfo().Mode(), modTime: hdr.ModTime}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+		case tar.TypeReg:
+			// The reader is left at the start of the contents of the file.
+			if e.offset, err = r.Seek(0, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			e.size = hdr.Size
+		default:
+			continue
+		}
+		t.files[name] = &e
+	}
+
+	var names = make([]string, 0, len(t.files))
+	for name := range t.files {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		t.link(name)
+	}
+	for _, e := range t.files {
+		sort.Slice(e.entries, func(i, j int) bool { return e.entries[i].Name() < e.entries[j].Name() })
+	}
+	return &t, nil
+}
+
+// link adds the file name to the entries of its directory, which is created
+// when the archive doesn't hold it.
+func (t *tarFS) link(name string) {
+	if name == "." {
+		return
+	}
+
+	var dir = path.Dir(name)
+	parent, ok := t.files[dir]
+	if !ok {
+		parent = &tarEntry{name: path.Base(dir), mode: fs.ModeDir | 0755}
+		t.files[dir] = parent
+		t.link(dir)
+	}
+	if parent.IsDir() {
+		parent.entries = append(parent.entries, fs.FileInfoToDirEntry(t.files[name]))
+	}
+}
+
+func (t *tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	e, ok := t.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if e.IsDir() {
+		return &dirFile{info: e, path: name, entries: e.entries}, nil
+	}
+	return &tarFile{entry: e, SectionReader: io.NewSectionReader(t.r, e.offset, e.size)}, nil
+}
+
+type tarFile struct {
+	*io.SectionReader
+	entry *tarEntry
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *tarFile) Close() error               { return nil }
+
+// dirFile is an open directory of a read-only fs.FS, whose entries are
+// sorted by name.
+type dirFile struct {
+	info    fs.FileInfo
+	path    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dirFile) Close() error               { return nil }
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: errors.New("is a directory")}
+}
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	var entries = d.entries[d.offset:]
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
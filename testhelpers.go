@@ -32,6 +32,10 @@ import (
 
 const fixtures = "fixtures"
 
+// copyFixtures copies the fixtures to dest on disk, for the tests which need
+// real files: the golden trees, the paths reported relative to the working
+// directory and the modification times of the cache. The other tests use the
+// in-memory fixtures of fixturesFS.
 func copyFixtures(t *testing.T, dest string) func() {
 	if err := copy(fixtures, dest); err != nil {
 		t.Fatal(err)
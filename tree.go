@@ -0,0 +1,84 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+// Separators of the names of the files of archives and git revisions from the
+// archive or revision, as in archive!path/inside and rev:path.
+const (
+	archiveSep = "!"
+	revSep     = ":"
+)
+
+// tree is the tree of files checked or fixed by a run: the names of fsys
+// below root, which are reported as paths of dir, or as <base><sep><name>
+// for the files of an archive or a git revision. The files can only be fixed
+// when fsys is a licensing.WriteFS.
+type tree struct {
+	fsys fs.FS
+	dir  string
+	root string
+	base string
+	sep  string
+}
+
+// osTree returns the tree of the file or directory found in path.
+func osTree(path string) tree {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return tree{fsys: licensing.DirFS(filepath.Dir(path)), dir: filepath.Dir(path), root: filepath.Base(path)}
+	}
+	return tree{fsys: licensing.DirFS(path), dir: path, root: "."}
+}
+
+// fsTree returns the tree of all the files of fsys, whose names are reported
+// as they are.
+func fsTree(fsys fs.FS) tree {
+	return tree{fsys: fsys, root: "."}
+}
+
+// path returns the path which the file name of the tree is reported as.
+func (t tree) path(name string) string {
+	if t.base != "" {
+		if name == "." {
+			return t.base
+		}
+		return t.base + t.sep + name
+	}
+	return filepath.Join(t.dir, filepath.FromSlash(name))
+}
+
+// excluded returns true when the file name of the tree is matched by the
+// exclusions. The files of an archive or a revision are matched by their name
+// relative to the root of the tree, the other files by their path.
+func (t tree) excluded(e exclusions, name string) bool {
+	if t.base == "" {
+		return e.match(t.path(name))
+	}
+	if name == t.root {
+		return false
+	}
+	return needsExclusion(strings.TrimPrefix(name, t.root+"/"), e.patterns)
+}
@@ -0,0 +1,252 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/elastic/go-licenser/licensing"
+)
+
+const (
+	defaultDebounce     = 500 * time.Millisecond
+	defaultPollInterval = time.Second
+)
+
+var watchUsageText = `
+Usage: go-licenser watch [flags] [path]
+
+  go-licenser watch monitors the specified path recursively and adds the license header to the
+  files as they are created or renamed. Bursts of events are debounced so that files written by
+  code generators are only processed once they're complete.
+
+Options:
+
+`[1:]
+
+type watchOp int
+
+const (
+	watchCreate watchOp = iota
+	watchWrite
+)
+
+// watchEvent is a change to a file in the watched tree.
+type watchEvent struct {
+	Path string
+	Op   watchOp
+}
+
+// watcher notifies the files which are created, renamed into or written to in
+// a tree. Directories created in the tree are watched as well. The Events
+// channel is closed once the watcher is closed.
+type watcher interface {
+	Events() <-chan watchEvent
+	Errors() <-chan error
+	Close() error
+}
+
+type watchOptions struct {
+	options
+	path     string
+	debounce time.Duration
+	interval time.Duration
+	poll     bool
+}
+
+// watchCommand parses the watch flags from args and runs the command until
+// it's interrupted.
+func watchCommand(args []string, out io.Writer) error {
+	var opts watchOptions
+	var exclude sliceFlag
+	var fs = flag.NewFlagSet("watch", flag.ExitOnError)
+	fs.Var(&exclude, "exclude", `path to exclude (can be specified multiple times).`)
+	fs.BoolVar(&opts.copyright, "copyright", false, "sets the copyright string as the first line")
+	fs.StringVar(&opts.ext, "ext", defaultExt, "sets the file extension to watch for.")
+	fs.StringVar(&opts.license, "license", defaultLicense, "sets the license type to apply.")
+	fs.StringVar(&opts.licensor, "licensor", defaultLicensor, "sets the name of the licensor")
+	fs.DurationVar(&opts.debounce, "debounce", defaultDebounce, "sets the quiet period to wait for after a burst of events.")
+	fs.BoolVar(&opts.poll, "poll", false, "polls the tree for changes instead of using file system notifications.")
+	fs.DurationVar(&opts.interval, "poll-interval", defaultPollInterval, "sets the interval between polls of the tree.")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), watchUsageText)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	opts.exclude = exclude
+	opts.path = defaultPath
+	if fs.NArg() > 0 {
+		opts.path = fs.Arg(0)
+	}
+
+	headerBytes, err := renderHeader(opts.options)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(opts.path); err != nil {
+		return &Error{err: err, code: exitFailedToStatTree}
+	}
+
+	var skip = func(path string) bool { return isExcluded(opts.path, path, opts.exclude) }
+	var w watcher
+	if opts.poll {
+		w, err = newPollWatcher(opts.path, skip, opts.interval)
+	} else {
+		w, err = newWatcher(opts.path, skip, opts.interval)
+	}
+	if err != nil {
+		return &Error{err: err, code: exitFailedToWalkPath}
+	}
+	defer w.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var logger = log.New(out, "", log.LstdFlags)
+	logger.Printf("watching %s for new %s files", opts.path, opts.ext)
+	return watch(ctx, w, headerBytes, opts, logger)
+}
+
+// watch consumes the events of the watcher until the context is done. Files
+// are processed once no events have been received for the debounce period.
+func watch(ctx context.Context, w watcher, headerBytes []byte, opts watchOptions, logger *log.Logger) error {
+	var pending = make(map[string]struct{})
+	var timer = time.NewTimer(opts.debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-w.Errors():
+			logger.Printf("watch error: %v", err)
+		case ev, ok := <-w.Events():
+			if !ok {
+				return nil
+			}
+
+			// Writes only delay the processing of files which are pending,
+			// existing files being edited are left alone.
+			if _, isPending := pending[ev.Path]; ev.Op == watchWrite && !isPending {
+				continue
+			}
+			pending[ev.Path] = struct{}{}
+
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(opts.debounce)
+		case <-timer.C:
+			var paths = make([]string, 0, len(pending))
+			for p := range pending {
+				paths = append(paths, p)
+			}
+			sort.Strings(paths)
+			pending = make(map[string]struct{})
+
+			for _, p := range paths {
+				watchFix(p, headerBytes, opts, logger)
+			}
+		}
+	}
+}
+
+// watchFix adds the license header to the file found in path when it matches
+// the options and is missing it.
+func watchFix(path string, headerBytes []byte, opts watchOptions, logger *log.Logger) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || filepath.Ext(path) != opts.ext {
+		return
+	}
+
+	if isExcluded(opts.path, path, opts.exclude) {
+		logger.Printf("%s: is excluded, skipping", path)
+		return
+	}
+
+	// The files with directives are left to the checks, which report their
+	// outcome.
+	if d, err := readDirectives(path); err == nil && d.Present() {
+		logger.Printf("%s: has a go-licenser directive, skipping", path)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		logger.Printf("%s: %v", path, err)
+		return
+	}
+	preamble, err := licensing.ReadPreamble(f)
+	f.Close()
+	if err != nil {
+		logger.Printf("%s: %v", path, err)
+		return
+	}
+	if licensing.ContainsHeader(bytes.NewReader(preamble), headerLines(preamble, opts.license, opts.options)) {
+		return
+	}
+
+	var fixOpts = opts.options
+	fixOpts.dry = false
+	var t = osTree(path)
+	var r = &logReporter{logger: logger}
+	if err := addOrCheckLicense(t, t.root, headerBytes, fs.FileInfoToDirEntry(info), fixOpts, r, nil, nil); err != nil {
+		logger.Printf("%s: failed adding the license header: %v", path, err)
+		return
+	}
+	// The findings of a fix, like binary files being skipped, explain why the
+	// file was left alone.
+	if r.reported {
+		return
+	}
+
+	logger.Printf("%s: added the license header", path)
+}
+
+// logReporter logs the findings of the files fixed by the watcher.
+type logReporter struct {
+	logger   *log.Logger
+	reported bool
+}
+
+func (r *logReporter) Report(f finding) {
+	r.logger.Printf("%s: %s", f.Path, f.Message)
+	r.reported = true
+}
+
+func (r *logReporter) Summarize(s *summary) {}
+
+func (r *logReporter) Flush() error { return nil }
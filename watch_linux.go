@@ -0,0 +1,206 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MOVED_TO | syscall.IN_CLOSE_WRITE
+
+// newWatcher returns an inotify based watcher, falling back to polling when
+// inotify can't be initialized (e.g. the user instance limit is reached).
+func newWatcher(root string, skip func(string) bool, interval time.Duration) (watcher, error) {
+	w, err := newInotifyWatcher(root, skip)
+	if err != nil {
+		return newPollWatcher(root, skip, interval)
+	}
+	return w, nil
+}
+
+// inotifyWatcher watches every directory of a tree with inotify. The watches
+// map is only accessed from the reading goroutine once the watcher is
+// created.
+type inotifyWatcher struct {
+	fd      int
+	f       *os.File
+	skip    func(string) bool
+	watches map[int32]string
+
+	events chan watchEvent
+	errors chan error
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newInotifyWatcher(root string, skip func(string) bool) (*inotifyWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	// Non blocking descriptors use the runtime poller, which allows Close
+	// to interrupt a pending Read.
+	var w = &inotifyWatcher{
+		fd:      fd,
+		f:       os.NewFile(uintptr(fd), "inotify"),
+		skip:    skip,
+		watches: make(map[int32]string),
+		events:  make(chan watchEvent, 128),
+		errors:  make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+
+	if err := w.addTree(root, false); err != nil {
+		w.f.Close()
+		return nil, err
+	}
+
+	go w.read()
+	return w, nil
+}
+
+func (w *inotifyWatcher) Events() <-chan watchEvent { return w.events }
+func (w *inotifyWatcher) Errors() <-chan error      { return w.errors }
+
+func (w *inotifyWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.f.Close()
+	})
+	return err
+}
+
+// addTree watches the directory and all its subdirectories. When emit is set,
+// the files found in the tree are notified as created, since they may have
+// been written before the watch was added.
+func (w *inotifyWatcher) addTree(root string, emit bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if path != root && w.skip(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.IsDir() {
+			if emit {
+				w.send(watchEvent{Path: path, Op: watchCreate})
+			}
+			return nil
+		}
+
+		wd, err := syscall.InotifyAddWatch(w.fd, path, inotifyMask)
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		w.watches[int32(wd)] = path
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) read() {
+	defer close(w.events)
+
+	var buf [syscall.SizeofInotifyEvent * 4096]byte
+	for {
+		n, err := w.f.Read(buf[:])
+		if errors.Is(err, os.ErrClosed) {
+			return
+		}
+		if err != nil {
+			w.error(err)
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			var raw = (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			var start = offset + syscall.SizeofInotifyEvent
+			var name = strings.TrimRight(string(buf[start:start+int(raw.Len)]), "\x00")
+			offset = start + int(raw.Len)
+
+			w.handle(raw, name)
+		}
+	}
+}
+
+func (w *inotifyWatcher) handle(raw *syscall.InotifyEvent, name string) {
+	if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+		w.error(errors.New("inotify event queue overflow, some files may have been missed"))
+		return
+	}
+
+	dir, ok := w.watches[raw.Wd]
+	if !ok {
+		return
+	}
+	if raw.Mask&syscall.IN_IGNORED != 0 {
+		delete(w.watches, raw.Wd)
+		return
+	}
+
+	var path = filepath.Join(dir, name)
+	if w.skip(path) {
+		return
+	}
+
+	switch {
+	case raw.Mask&syscall.IN_ISDIR != 0:
+		if raw.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+			if err := w.addTree(path, true); err != nil {
+				w.error(err)
+			}
+		}
+	case raw.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		w.send(watchEvent{Path: path, Op: watchCreate})
+	case raw.Mask&syscall.IN_CLOSE_WRITE != 0:
+		w.send(watchEvent{Path: path, Op: watchWrite})
+	}
+}
+
+// send notifies the event unless the watcher is closed.
+func (w *inotifyWatcher) send(ev watchEvent) {
+	select {
+	case w.events <- ev:
+	case <-w.done:
+	}
+}
+
+func (w *inotifyWatcher) error(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
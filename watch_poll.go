@@ -0,0 +1,143 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pollWatcher detects changes by walking the tree at a fixed interval and
+// comparing the files found against the previous walk. It works on every
+// platform and file system, including network mounts which don't support
+// notifications.
+type pollWatcher struct {
+	root     string
+	skip     func(string) bool
+	interval time.Duration
+	files    map[string]pollState
+
+	events chan watchEvent
+	errors chan error
+	done   chan struct{}
+	once   sync.Once
+}
+
+type pollState struct {
+	size    int64
+	modTime time.Time
+}
+
+func newPollWatcher(root string, skip func(string) bool, interval time.Duration) (*pollWatcher, error) {
+	var w = &pollWatcher{
+		root:     root,
+		skip:     skip,
+		interval: interval,
+		events:   make(chan watchEvent, 128),
+		errors:   make(chan error, 1),
+		done:     make(chan struct{}),
+	}
+
+	files, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.files = files
+
+	go w.loop()
+	return w, nil
+}
+
+func (w *pollWatcher) Events() <-chan watchEvent { return w.events }
+func (w *pollWatcher) Errors() <-chan error      { return w.errors }
+
+func (w *pollWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+func (w *pollWatcher) loop() {
+	defer close(w.events)
+
+	var ticker = time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		files, err := w.scan()
+		if err != nil {
+			select {
+			case w.errors <- err:
+			default:
+			}
+			continue
+		}
+
+		for path, st := range files {
+			var ev = watchEvent{Path: path, Op: watchCreate}
+			if old, ok := w.files[path]; ok {
+				if old == st {
+					continue
+				}
+				ev.Op = watchWrite
+			}
+
+			select {
+			case w.events <- ev:
+			case <-w.done:
+				return
+			}
+		}
+		w.files = files
+	}
+}
+
+func (w *pollWatcher) scan() (map[string]pollState, error) {
+	var files = make(map[string]pollState)
+	err := filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files can be removed while the tree is walked.
+			return nil
+		}
+		if path != w.root && w.skip(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files[path] = pollState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+
+	return files, err
+}
@@ -0,0 +1,204 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeWatcher struct {
+	events chan watchEvent
+	errors chan error
+}
+
+func (w *fakeWatcher) Events() <-chan watchEvent { return w.events }
+func (w *fakeWatcher) Errors() <-chan error      { return w.errors }
+func (w *fakeWatcher) Close() error              { close(w.events); return nil }
+
+// lockedBuffer is a bytes.Buffer which can be written and read concurrently.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func Test_watch(t *testing.T) {
+	var root = t.TempDir()
+	var files = map[string]string{
+		"new.go":           "package main\n",
+		"excluded/new.go":  "package excluded\n",
+		"vendor/dep/x.go":  "package dep\n",
+		"README.md":        "# readme\n",
+		"existing.go":      "package main\n",
+		"generated/gen.go": "package generated\n",
+		"binary.go":        "// caf\xe9\npackage main\n",
+	}
+	writeFiles(t, root, files)
+
+	var opts = watchOptions{
+		options: options{
+			license:  defaultLicense,
+			licensor: defaultLicensor,
+			exclude:  []string{"excluded"},
+			ext:      defaultExt,
+		},
+		path:     root,
+		debounce: 50 * time.Millisecond,
+	}
+	headerBytes, err := renderHeader(opts.options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var w = &fakeWatcher{events: make(chan watchEvent, 16), errors: make(chan error)}
+	var buf = new(lockedBuffer)
+	ctx, cancel := context.WithCancel(context.Background())
+	var done = make(chan error)
+	go func() { done <- watch(ctx, w, headerBytes, opts, log.New(buf, "", 0)) }()
+
+	for _, ev := range []watchEvent{
+		{Path: filepath.Join(root, "new.go"), Op: watchCreate},
+		{Path: filepath.Join(root, "excluded", "new.go"), Op: watchCreate},
+		{Path: filepath.Join(root, "vendor", "dep", "x.go"), Op: watchCreate},
+		{Path: filepath.Join(root, "README.md"), Op: watchCreate},
+		{Path: filepath.Join(root, "existing.go"), Op: watchWrite},
+		{Path: filepath.Join(root, "generated", "gen.go"), Op: watchCreate},
+		{Path: filepath.Join(root, "generated", "gen.go"), Op: watchWrite},
+		{Path: filepath.Join(root, "binary.go"), Op: watchCreate},
+	} {
+		w.events <- ev
+	}
+
+	var deadline = time.Now().Add(5 * time.Second)
+	for strings.Count(buf.String(), "\n") < 5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	var want = filepath.FromSlash(strings.ReplaceAll(`
+ROOT/binary.go: is skipped as a binary file, it isn't valid UTF-8
+ROOT/excluded/new.go: is excluded, skipping
+ROOT/generated/gen.go: added the license header
+ROOT/new.go: added the license header
+ROOT/vendor/dep/x.go: is excluded, skipping
+`[1:], "ROOT", filepath.ToSlash(root)))
+	if got := buf.String(); got != want {
+		t.Errorf("watch() output = \n%s\n want \n%s", got, want)
+	}
+
+	for name, fixed := range map[string]bool{
+		"new.go":           true,
+		"generated/gen.go": true,
+		"excluded/new.go":  false,
+		"vendor/dep/x.go":  false,
+		"existing.go":      false,
+		"binary.go":        false,
+	} {
+		got, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.HasPrefix(got, headerBytes) != fixed {
+			t.Errorf("%s: header added = %v, want %v", name, !fixed, fixed)
+		}
+	}
+}
+
+func testWatcher(t *testing.T, newWatcher func(root string) (watcher, error)) {
+	var root = t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "vendor"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := newWatcher(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	var created = []string{
+		filepath.Join(root, "new.go"),
+		filepath.Join(root, "sub", "dir", "new.go"),
+	}
+	for _, p := range append(created, filepath.Join(root, "vendor", "dep.go")) {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var seen = make(map[string]bool)
+	var timeout = time.After(5 * time.Second)
+	for len(seen) < len(created) {
+		select {
+		case ev := <-w.Events():
+			if strings.Contains(ev.Path, "vendor") {
+				t.Errorf("excluded file %s was notified", ev.Path)
+			}
+			if ev.Op == watchCreate {
+				seen[ev.Path] = true
+			}
+		case err := <-w.Errors():
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("timed out waiting for the created files, got %v", seen)
+		}
+	}
+
+	for _, p := range created {
+		if !seen[p] {
+			t.Errorf("%s creation wasn't notified", p)
+		}
+	}
+}
+
+func Test_newWatcher(t *testing.T) {
+	testWatcher(t, func(root string) (watcher, error) {
+		return newWatcher(root, func(p string) bool { return isExcluded(root, p, nil) }, 10*time.Millisecond)
+	})
+}
+
+func Test_pollWatcher(t *testing.T) {
+	testWatcher(t, func(root string) (watcher, error) {
+		return newPollWatcher(root, func(p string) bool { return isExcluded(root, p, nil) }, 10*time.Millisecond)
+	})
+}